@@ -1,68 +1,207 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/MosaabBleik/inventory-service/internal/auth"
 	product_clients "github.com/MosaabBleik/inventory-service/internal/clients"
+	"github.com/MosaabBleik/inventory-service/internal/config"
 	"github.com/MosaabBleik/inventory-service/internal/database"
 	"github.com/MosaabBleik/inventory-service/internal/handlers"
+	"github.com/MosaabBleik/inventory-service/internal/logging"
+	"github.com/MosaabBleik/inventory-service/internal/metrics"
 	"github.com/MosaabBleik/inventory-service/internal/middleware"
-	"github.com/MosaabBleik/inventory-service/internal/models"
+	"github.com/MosaabBleik/inventory-service/internal/telemetry"
+	"github.com/MosaabBleik/inventory-service/internal/tenant"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// Load env vars
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+	// Schema migrations, e.g. inventory-service migrate status, need only the database
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		cfg, err := config.LoadDatabase()
+		if err != nil {
+			log.Fatalf("Invalid configuration:\n%v", err)
+		}
+		if err := logging.Setup("inventory-service", cfg.Log.Level); err != nil {
+			log.Fatalf("Logging configuration failed: %v", err)
+		}
+		if err := runMigrate(cfg.Database.URL, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
 
-	// Connect to database
-	db := database.Connect()
-
-	// Auto migration
-	err := db.AutoMigrate(&models.Inventory{})
+	// Defaults, CONFIG_FILE, .env and env vars, validated together
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Migration failed: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
-	productsURL := os.Getenv("PRODUCTS_SERVICE_URL")
-	if productsURL == "" {
-		productsURL = "http://localhost:8080"
+	// JSON logs, at the level set by LOG_LEVEL
+	if err := logging.Setup("inventory-service", cfg.Log.Level); err != nil {
+		log.Fatalf("Logging configuration failed: %v", err)
 	}
 
-	timeoutStr := os.Getenv("REQUEST_TIMEOUT")
-	timeoutSeconds, err := strconv.Atoi(timeoutStr)
+	// Traces are exported when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := telemetry.Init(context.Background(), "inventory-service")
 	if err != nil {
-		// default timeout duration (seconds)
-		timeoutSeconds = 5
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
 
-	productsClient := product_clients.NewProductsClient(productsURL, timeoutSeconds)
+	// Pending migrations are applied at startup unless disabled
+	if cfg.Database.MigrateOnStart {
+		if err := database.MigrateUp(cfg.Database.URL); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	}
+
+	// Connect to database
+	db := database.Connect(cfg.Database.URL)
+	if err := metrics.RegisterDB(db, "inventory"); err != nil {
+		log.Fatalf("Failed to register database metrics: %v", err)
+	}
+
+	// The inventory service calls the products service with its own API key
+	productsClient := product_clients.NewProductsClient(cfg.Products)
 
 	inventoryHandler := &handlers.InventoryHandler{
 		DB:             db,
 		ProductsClient: productsClient,
+		ItemTimeout:    cfg.Handlers.ItemTimeout,
+	}
+
+	configHandler := &handlers.ConfigHandler{
+		Config: cfg,
 	}
 
+	// Retried POST requests with an Idempotency-Key replay the first response
+	idempotencyStore := &database.IdempotencyStore{DB: db}
+	idempotent := middleware.Idempotency(idempotencyStore, cfg.IdempotencyTTL)
+
+	// Background work runs until shutdown
+	workCtx, stopWork := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		purgeIdempotencyKeys(workCtx, idempotencyStore)
+	}()
+
+	// Reads need any inventory role, stock changes need inventory-operator
+	authenticator, err := auth.FromConfig(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Auth configuration failed: %v", err)
+	}
+	read := authenticator.Require(auth.RoleReader, auth.RoleInventoryOperator)
+	write := authenticator.Require(auth.RoleInventoryOperator)
+
+	// API routes are bounded in time and body size, and those taking a body
+	// take JSON only. Stock reads get a shorter bound, availability checks of
+	// many items a longer one.
+	bounded := func(timeout time.Duration) func(http.Handler) http.Handler {
+		return middleware.Chain(middleware.Timeout(timeout), middleware.MaxBytes(int64(cfg.Handlers.MaxBodyBytes)))
+	}
+	readAPI := bounded(cfg.Handlers.ReadTimeout)
+	jsonAPI := middleware.Chain(bounded(cfg.Handlers.Timeout), middleware.RequireJSON)
+	availabilityAPI := middleware.Chain(bounded(cfg.Handlers.AvailabilityTimeout), middleware.RequireJSON)
+
 	r := mux.NewRouter()
-	r.HandleFunc("/api/inventory", inventoryHandler.AddInventory).Methods("POST")
-	r.HandleFunc("/api/inventory/low-stock", inventoryHandler.LowStock).Methods("GET")
-	r.HandleFunc("/api/inventory/{product_id}", inventoryHandler.Stock).Methods("GET")
-	r.HandleFunc("/api/inventory/{product_id}", inventoryHandler.UpdateStock).Methods("PUT")
-	r.HandleFunc("/api/inventory/check-availability", inventoryHandler.CheckAvailability).Methods("POST")
-	r.HandleFunc("/api/health", inventoryHandler.HealthCheck).Methods("GET")
+	r.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowed)
+	r.Handle("/api/inventory", write(jsonAPI(idempotent(http.HandlerFunc(inventoryHandler.AddInventory))))).Methods("POST")
+	r.Handle("/api/inventory/low-stock", read(readAPI(http.HandlerFunc(inventoryHandler.LowStock)))).Methods("GET")
+	r.Handle("/api/inventory/variants/{variant_id}", read(readAPI(http.HandlerFunc(inventoryHandler.VariantStock)))).Methods("GET")
+	r.Handle("/api/inventory/{product_id}", read(readAPI(http.HandlerFunc(inventoryHandler.Stock)))).Methods("GET")
+	r.Handle("/api/inventory/{product_id}", write(jsonAPI(http.HandlerFunc(inventoryHandler.UpdateStock)))).Methods("PUT")
+	r.Handle("/api/inventory/check-availability", read(availabilityAPI(http.HandlerFunc(inventoryHandler.CheckAvailability)))).Methods("POST")
+
+	// Liveness and readiness probes, /api/health is kept for existing checks
+	r.HandleFunc("/livez", inventoryHandler.Live).Methods("GET")
+	r.HandleFunc("/readyz", inventoryHandler.Ready).Methods("GET")
+	r.HandleFunc("/api/health", inventoryHandler.Ready).Methods("GET")
 
-	loggedRouter := middleware.Logger(r)
+	// Effective configuration, secrets redacted
+	r.Handle("/api/admin/config", write(readAPI(http.HandlerFunc(configHandler.GetConfig)))).Methods("GET")
 
-	port := os.Getenv("PORT")
-	portStr := fmt.Sprintf(":%s", port)
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
-	fmt.Println("Server started at :", port)
-	log.Fatal(http.ListenAndServe(portStr, loggedRouter))
+	// Every request belongs to a tenant, resolved once the caller is known.
+	// Panics are recovered inside the logger so they are logged as 500s.
+	loggedRouter := middleware.Chain(
+		middleware.Tracing(r, "inventory-service"),
+		middleware.RequestID,
+		middleware.Metrics(r),
+		middleware.Logger,
+		middleware.Recover,
+		authenticator.Authenticate,
+		tenant.Resolve,
+	)(r)
+
+	server := newServer(cfg.Server, loggedRouter)
+
+	// SIGINT or SIGTERM starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		slog.Info("Server started", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down")
+
+	// In-flight stock changes are drained before the DB is closed
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain requests", "error", err)
+	}
+	stopWork()
+	if err := wait(shutdownCtx, &workers); err != nil {
+		slog.Error("Background workers did not stop", "error", err)
+	}
+
+	if err := database.Close(db); err != nil {
+		slog.Error("Failed to close DB", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// purgeIdempotencyKeys removes expired idempotency records every hour until
+// ctx is cancelled.
+func purgeIdempotencyKeys(ctx context.Context, store *database.IdempotencyStore) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := store.PurgeExpired(ctx); err != nil {
+			slog.Error("failed to purge idempotency keys", "error", err)
+		}
+	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MosaabBleik/inventory-service/internal/database"
+	"github.com/golang-migrate/migrate/v4"
+)
+
+const migrateUsage = `usage: inventory-service migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [n]       roll back n migrations (default 1)
+  to <version>   migrate up or down to version
+  force <version> mark version as applied after fixing a dirty migration
+  status         show the current version and pending migrations`
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(dsn string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := database.Migrator(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		err = m.Steps(-steps)
+
+	case "to", "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, convErr := strconv.ParseUint(args[1], 10, 32)
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "to" {
+			err = m.Migrate(uint(version))
+		} else {
+			err = m.Force(int(version))
+		}
+
+	case "status":
+		return printMigrationStatus(m)
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("No change")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return printMigrationStatus(m)
+}
+
+func printMigrationStatus(m *migrate.Migrate) error {
+	current, dirty, list, err := database.Migrations(m)
+	if err != nil {
+		return err
+	}
+
+	state := "clean"
+	if dirty {
+		state = "dirty, fix it and run migrate force <version>"
+	}
+	fmt.Printf("Current version: %d (%s)\n", current, state)
+
+	for _, migration := range list {
+		mark := " "
+		if migration.Applied {
+			mark = "x"
+		}
+		fmt.Printf("  [%s] %s\n", mark, migration.Name)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MosaabBleik/inventory-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Authenticator verifies bearer tokens and API keys.
+type Authenticator struct {
+	// Secret verifies HMAC signed tokens
+	Secret []byte
+	// PublicKey verifies RSA or ECDSA signed tokens without a JWKS
+	PublicKey any
+	// JWKS resolves signing keys by key ID
+	JWKS *JWKS
+
+	Issuer      string
+	Audience    string
+	RolesClaim  string
+	TenantClaim string
+
+	// Disabled lets every request through, for local development only
+	Disabled bool
+
+	// apiKeys maps the SHA-256 of each key to its principal, so keys are not
+	// compared in a timing dependent way
+	apiKeys map[string]Principal
+}
+
+// AddAPIKey accepts key for a service with roles. A key without a tenant may
+// act on any tenant.
+func (a *Authenticator) AddAPIKey(name, key string, roles []string, tenant string) {
+	if a.apiKeys == nil {
+		a.apiKeys = make(map[string]Principal)
+	}
+	a.apiKeys[hashKey(key)] = Principal{Subject: name, Roles: roles, Tenant: tenant, Service: true}
+}
+
+// Enabled reports whether any credential can be verified.
+func (a *Authenticator) Enabled() bool {
+	return len(a.Secret) > 0 || a.PublicKey != nil || a.JWKS != nil || len(a.apiKeys) > 0
+}
+
+func (a *Authenticator) VerifyAPIKey(key string) (Principal, error) {
+	principal, ok := a.apiKeys[hashKey(key)]
+	if !ok {
+		return Principal{}, errors.New("invalid API key")
+	}
+	return principal, nil
+}
+
+// VerifyToken checks the signature, expiry, issuer and audience of a JWT and
+// returns its subject and roles.
+func (a *Authenticator) VerifyToken(ctx context.Context, token string) (Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(a.methods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if a.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return a.key(ctx, t)
+	}, opts...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid token: %w", err)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return Principal{}, errors.New("invalid token: missing sub claim")
+	}
+	tenant, _ := claims[a.TenantClaim].(string)
+	return Principal{Subject: subject, Roles: rolesFromClaims(claims, a.RolesClaim), Tenant: tenant}, nil
+}
+
+// methods lists the algorithms the configured keys can verify, so a token
+// cannot pick an algorithm the server did not intend.
+func (a *Authenticator) methods() []string {
+	var methods []string
+	if len(a.Secret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if a.PublicKey != nil || a.JWKS != nil {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+	return methods
+}
+
+func (a *Authenticator) key(ctx context.Context, t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.Secret, nil
+	}
+
+	if kid, _ := t.Header["kid"].(string); kid != "" && a.JWKS != nil {
+		return a.JWKS.Key(ctx, kid)
+	}
+	if a.PublicKey != nil {
+		return a.PublicKey, nil
+	}
+	return nil, errors.New("no key to verify the token")
+}
+
+// rolesFromClaims reads roles from an array claim or a space separated
+// string, e.g. an OAuth scope.
+func rolesFromClaims(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromConfig configures an Authenticator from c, e.g. with the variables:
+//
+//	AUTH_JWT_SECRET           HMAC secret
+//	AUTH_JWT_PUBLIC_KEY_FILE  PEM encoded RSA or ECDSA public key
+//	AUTH_JWKS_URL             JWKS endpoint of the identity provider
+//	AUTH_JWT_ISSUER           expected iss claim
+//	AUTH_JWT_AUDIENCE         expected aud claim
+//	AUTH_ROLES_CLAIM          claim holding the roles, "roles" by default
+//	AUTH_TENANT_CLAIM         claim holding the tenant, "tenant_id" by default
+//	AUTH_API_KEYS             name:key:role|role[:tenant], comma separated
+//	AUTH_DISABLED             true to skip authentication
+//
+// Without any credential configured it fails, so a service never starts
+// open by accident.
+func FromConfig(c config.Auth) (*Authenticator, error) {
+	a := &Authenticator{
+		Secret:      []byte(c.JWTSecret),
+		Issuer:      c.JWTIssuer,
+		Audience:    c.JWTAudience,
+		RolesClaim:  c.RolesClaim,
+		TenantClaim: c.TenantClaim,
+	}
+	if a.RolesClaim == "" {
+		a.RolesClaim = "roles"
+	}
+	if a.TenantClaim == "" {
+		a.TenantClaim = "tenant_id"
+	}
+
+	if path := c.JWTPublicKeyFile; path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AUTH_JWT_PUBLIC_KEY_FILE: %w", err)
+		}
+		if a.PublicKey, err = parsePublicKey(pem); err != nil {
+			return nil, fmt.Errorf("invalid AUTH_JWT_PUBLIC_KEY_FILE: %w", err)
+		}
+	}
+
+	if c.JWKSURL != "" {
+		a.JWKS = NewJWKS(c.JWKSURL)
+	}
+
+	for _, entry := range strings.Split(c.APIKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("AUTH_API_KEYS entries must look like name:key:role|role or name:key:role|role:tenant")
+		}
+		tenant := ""
+		if len(parts) == 4 {
+			tenant = strings.ToLower(strings.TrimSpace(parts[3]))
+			if !ValidTenant(tenant) {
+				return nil, fmt.Errorf("AUTH_API_KEYS entry %s has an invalid tenant %q", parts[0], parts[3])
+			}
+		}
+		a.AddAPIKey(parts[0], parts[1], strings.Split(parts[2], "|"), tenant)
+	}
+
+	if c.Disabled {
+		slog.Warn("auth: AUTH_DISABLED is set, every route is open")
+		a.Disabled = true
+		return a, nil
+	}
+	if !a.Enabled() {
+		return nil, errors.New("no credentials configured, set AUTH_JWT_SECRET, AUTH_JWT_PUBLIC_KEY_FILE, AUTH_JWKS_URL or AUTH_API_KEYS, or AUTH_DISABLED=true")
+	}
+	if len(a.Secret) > 0 && len(a.Secret) < 32 {
+		slog.Warn("auth: AUTH_JWT_SECRET is shorter than 32 bytes")
+	}
+	return a, nil
+}
+
+func parsePublicKey(pem []byte) (any, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("not an RSA or ECDSA public key")
+}
@@ -0,0 +1,366 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MosaabBleik/inventory-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// validClaims returns claims the test authenticator accepts, changed by
+// edit.
+func validClaims(edit func(jwt.MapClaims)) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub":       "user-1",
+		"iss":       "https://idp.example.com",
+		"aud":       "inventory",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"roles":     []string{RoleInventoryOperator, RoleReader},
+		"tenant_id": "acme",
+	}
+	if edit != nil {
+		edit(claims)
+	}
+	return claims
+}
+
+func TestVerifyToken(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacOnly := &Authenticator{
+		Secret:      []byte(testSecret),
+		Issuer:      "https://idp.example.com",
+		Audience:    "inventory",
+		RolesClaim:  "roles",
+		TenantClaim: "tenant_id",
+	}
+	withPublicKey := &Authenticator{
+		PublicKey:   &ecKey.PublicKey,
+		RolesClaim:  "scope",
+		TenantClaim: "org",
+	}
+
+	tests := []struct {
+		name    string
+		auth    *Authenticator
+		token   string
+		want    Principal
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			auth:  hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(nil)),
+			want:  Principal{Subject: "user-1", Roles: []string{RoleInventoryOperator, RoleReader}, Tenant: "acme"},
+		},
+		{
+			name: "without tenant",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS512, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				delete(c, "tenant_id")
+				delete(c, "roles")
+			})),
+			want: Principal{Subject: "user-1"},
+		},
+		{
+			name: "expired within leeway",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				c["exp"] = time.Now().Add(-10 * time.Second).Unix()
+			})),
+			want: Principal{Subject: "user-1", Roles: []string{RoleInventoryOperator, RoleReader}, Tenant: "acme"},
+		},
+		{
+			name: "expired",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				c["exp"] = time.Now().Add(-time.Hour).Unix()
+			})),
+			wantErr: "expired",
+		},
+		{
+			name: "without expiry",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				delete(c, "exp")
+			})),
+			wantErr: "exp",
+		},
+		{
+			name: "other issuer",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				c["iss"] = "https://evil.example.com"
+			})),
+			wantErr: "issuer",
+		},
+		{
+			name: "other audience",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				c["aud"] = "products"
+			})),
+			wantErr: "audience",
+		},
+		{
+			name: "without subject",
+			auth: hmacOnly,
+			token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(func(c jwt.MapClaims) {
+				delete(c, "sub")
+			})),
+			wantErr: "missing sub claim",
+		},
+		{
+			name:    "wrong secret",
+			auth:    hmacOnly,
+			token:   sign(t, jwt.SigningMethodHS256, []byte("another secret of at least 32 bytes"), validClaims(nil)),
+			wantErr: "signature is invalid",
+		},
+		{
+			name:    "unsigned",
+			auth:    hmacOnly,
+			token:   sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims(nil)),
+			wantErr: "signing method",
+		},
+		{
+			name:    "algorithm not configured",
+			auth:    hmacOnly,
+			token:   sign(t, jwt.SigningMethodES256, ecKey, validClaims(nil)),
+			wantErr: "signing method",
+		},
+		{
+			name:    "HMAC with a public key only",
+			auth:    withPublicKey,
+			token:   sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(nil)),
+			wantErr: "signing method",
+		},
+		{
+			name: "public key with scope roles",
+			auth: withPublicKey,
+			token: sign(t, jwt.SigningMethodES256, ecKey, validClaims(func(c jwt.MapClaims) {
+				c["scope"] = "reader  inventory-operator"
+				c["org"] = "globex"
+			})),
+			want: Principal{Subject: "user-1", Roles: []string{RoleReader, RoleInventoryOperator}, Tenant: "globex"},
+		},
+		{
+			name:    "public key mismatch",
+			auth:    withPublicKey,
+			token:   sign(t, jwt.SigningMethodES256, otherKey, validClaims(nil)),
+			wantErr: "invalid token",
+		},
+		{
+			name:    "garbage",
+			auth:    hmacOnly,
+			token:   "not.a.token",
+			wantErr: "invalid token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.auth.VerifyToken(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("VerifyToken() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyToken() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VerifyToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.Auth
+		wantErr  string
+		key      string
+		wantKey  Principal
+		disabled bool
+	}{
+		{
+			name:    "nothing configured",
+			wantErr: "no credentials configured",
+		},
+		{
+			name:     "disabled",
+			config:   config.Auth{Disabled: true},
+			disabled: true,
+		},
+		{
+			name:    "shared key",
+			config:  config.Auth{APIKeys: "inventory:s3cret:reader|inventory-operator"},
+			key:     "s3cret",
+			wantKey: Principal{Subject: "inventory", Roles: []string{RoleReader, RoleInventoryOperator}, Service: true},
+		},
+		{
+			name:    "tenant key among others",
+			config:  config.Auth{APIKeys: " inventory:s3cret:reader , acme:acme-key:inventory-operator:acme ,"},
+			key:     "acme-key",
+			wantKey: Principal{Subject: "acme", Roles: []string{RoleInventoryOperator}, Tenant: "acme", Service: true},
+		},
+		{
+			name:    "tenant normalized",
+			config:  config.Auth{APIKeys: "acme:acme-key:reader: Acme "},
+			key:     "acme-key",
+			wantKey: Principal{Subject: "acme", Roles: []string{RoleReader}, Tenant: "acme", Service: true},
+		},
+		{
+			name:    "key with invalid tenant",
+			config:  config.Auth{APIKeys: "acme:acme-key:reader:a*"},
+			wantErr: `invalid tenant "a*"`,
+		},
+		{
+			name:    "key without roles",
+			config:  config.Auth{APIKeys: "inventory:s3cret"},
+			wantErr: "AUTH_API_KEYS",
+		},
+		{
+			name:    "key without name",
+			config:  config.Auth{APIKeys: ":s3cret:reader"},
+			wantErr: "AUTH_API_KEYS",
+		},
+		{
+			name:    "key with too many parts",
+			config:  config.Auth{APIKeys: "inventory:s3cret:reader:acme:extra"},
+			wantErr: "AUTH_API_KEYS",
+		},
+		{
+			name:    "missing public key file",
+			config:  config.Auth{JWTPublicKeyFile: "/nonexistent/key.pem"},
+			wantErr: "AUTH_JWT_PUBLIC_KEY_FILE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := FromConfig(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromConfig() error = %v", err)
+			}
+			if a.Disabled != tt.disabled {
+				t.Errorf("Disabled = %v, want %v", a.Disabled, tt.disabled)
+			}
+			if a.RolesClaim != "roles" || a.TenantClaim != "tenant_id" {
+				t.Errorf("claims = %q, %q, want the defaults", a.RolesClaim, a.TenantClaim)
+			}
+			if tt.key == "" {
+				return
+			}
+			got, err := a.VerifyAPIKey(tt.key)
+			if err != nil {
+				t.Fatalf("VerifyAPIKey() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.wantKey) {
+				t.Errorf("VerifyAPIKey() = %+v, want %+v", got, tt.wantKey)
+			}
+			if _, err := a.VerifyAPIKey(tt.key + "x"); err == nil {
+				t.Error("VerifyAPIKey() accepted another key")
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	a := &Authenticator{Secret: []byte(testSecret), RolesClaim: "roles", TenantClaim: "tenant_id"}
+	a.AddAPIKey("inventory", "reader-key", []string{RoleReader}, "")
+	a.AddAPIKey("admin", "admin-key", []string{RoleInventoryOperator}, "")
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		wantStatus    int
+		wantSubject   string
+	}{
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+		{name: "matching key", apiKey: "admin-key", wantStatus: http.StatusOK, wantSubject: "admin"},
+		{name: "key without role", apiKey: "reader-key", wantStatus: http.StatusForbidden},
+		{name: "unknown key", apiKey: "guess", wantStatus: http.StatusUnauthorized},
+		{
+			name:          "matching token",
+			authorization: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(nil)),
+			wantStatus:    http.StatusOK,
+			wantSubject:   "user-1",
+		},
+		{
+			name:          "lowercase scheme",
+			authorization: "bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), validClaims(nil)),
+			wantStatus:    http.StatusOK,
+			wantSubject:   "user-1",
+		},
+		{name: "basic auth", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized},
+		{name: "empty bearer", authorization: "Bearer ", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer abc", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			handler := a.Authenticate(a.Require(RoleInventoryOperator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, _ := FromContext(r.Context())
+				subject = principal.Subject
+			})))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/inventory", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", subject, tt.wantSubject)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksMaxAge is how long fetched keys are used before a refresh
+	jwksMaxAge = 15 * time.Minute
+	// jwksMinRefresh limits refetches caused by unknown key IDs or failures
+	jwksMinRefresh = time.Minute
+)
+
+// JWKS fetches and caches the signing keys of an identity provider. Keys are
+// refetched when they expire or a token names a key ID not seen yet, so key
+// rotation needs no restart. Fetches happen outside the lock, one at a time,
+// and known keys keep being served while one runs.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]any
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// refreshing is closed when the fetch in flight, if any, is done
+	refreshing chan struct{}
+}
+
+func NewJWKS(url string) *JWKS {
+	return &JWKS{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Key returns the public key with key ID kid. An expired key is returned
+// right away while the keys are refreshed in the background, an unknown one
+// waits for the refresh.
+func (j *JWKS) Key(ctx context.Context, kid string) (any, error) {
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	if ok && time.Since(j.fetchedAt) < jwksMaxAge {
+		j.mu.Unlock()
+		return key, nil
+	}
+	done := j.refresh(ctx)
+	j.mu.Unlock()
+
+	// Keep verifying with the keys we have while they are refreshed or the
+	// provider is down
+	if ok {
+		return key, nil
+	}
+	if done == nil {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// refresh starts fetching the keys unless a fetch is in flight or one was
+// attempted too recently, and returns a channel closed once the fetch in
+// flight is done, or nil. It must be called with j.mu held.
+func (j *JWKS) refresh(ctx context.Context) chan struct{} {
+	if j.refreshing != nil {
+		return j.refreshing
+	}
+	if time.Since(j.attemptedAt) < jwksMinRefresh {
+		return nil
+	}
+	j.attemptedAt = time.Now()
+
+	done := make(chan struct{})
+	j.refreshing = done
+
+	// The fetch serves every waiting request, so it outlives this one
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		keys, err := j.fetch(ctx)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if err != nil {
+			slog.WarnContext(ctx, "auth: failed to refresh JWKS", "error", err)
+		} else {
+			j.keys = keys
+			j.fetchedAt = time.Now()
+		}
+		j.refreshing = nil
+		close(done)
+	}()
+	return done
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Unsupported key types are skipped, not fatal
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.New("unsupported key type")
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{Kid: kid, Kty: "RSA", Use: "sig", N: encodeBigInt(key.N), E: encodeBigInt(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) jwk {
+	return jwk{Kid: kid, Kty: "EC", Crv: key.Curve.Params().Name, X: encodeBigInt(key.X), Y: encodeBigInt(key.Y)}
+}
+
+// jwksServer serves the key set returned by keys and counts the fetches.
+func jwksServer(t *testing.T, keys func() []jwk) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		set := keys()
+		if set == nil {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": set})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func TestJWKSVerifyToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, _ := jwksServer(t, func() []jwk {
+		enc := ecJWK("enc", &encKey.PublicKey)
+		enc.Use = "enc"
+		return []jwk{
+			rsaJWK("rsa-1", &rsaKey.PublicKey),
+			ecJWK("ec-1", &ecKey.PublicKey),
+			enc,
+			{Kid: "oct-1", Kty: "oct"},
+		}
+	})
+	a := &Authenticator{JWKS: NewJWKS(srv.URL), RolesClaim: "roles", TenantClaim: "tenant_id"}
+
+	withKid := func(method jwt.SigningMethod, key any, kid string) string {
+		token := jwt.NewWithClaims(method, validClaims(nil))
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "RSA key", token: withKid(jwt.SigningMethodRS256, rsaKey, "rsa-1")},
+		{name: "RSA-PSS with an RSA key", token: withKid(jwt.SigningMethodPS256, rsaKey, "rsa-1")},
+		{name: "EC key", token: withKid(jwt.SigningMethodES256, ecKey, "ec-1")},
+		{name: "key of another ID", token: withKid(jwt.SigningMethodES256, ecKey, "rsa-1"), wantErr: "invalid token"},
+		{name: "encryption key", token: withKid(jwt.SigningMethodES256, encKey, "enc"), wantErr: `unknown key ID "enc"`},
+		{name: "unsupported key type", token: withKid(jwt.SigningMethodES256, ecKey, "oct-1"), wantErr: `unknown key ID "oct-1"`},
+		{name: "unknown key ID", token: withKid(jwt.SigningMethodES256, ecKey, "ec-2"), wantErr: `unknown key ID "ec-2"`},
+		{name: "HMAC without a secret", token: withKid(jwt.SigningMethodHS256, []byte(testSecret), "ec-1"), wantErr: "signing method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.VerifyToken(context.Background(), tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyToken() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("VerifyToken() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWKSKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := []jwk{ecJWK("ec-1", &ecKey.PublicKey)}
+
+	tests := []struct {
+		name string
+		// served is the key set of the provider, nil when it is down
+		served []jwk
+		// cached is the key set already fetched, and how long ago
+		cached    []jwk
+		cachedAge time.Duration
+		// attempted is how long ago the last fetch was attempted, none if zero
+		attempted time.Duration
+		kid       string
+		wantErr   bool
+		// wantFetch is whether a fetch is made before Key returns
+		wantFetch bool
+	}{
+		{name: "first use", served: known, kid: "ec-1", wantFetch: true},
+		{name: "cached", served: known, cached: known, cachedAge: time.Minute, attempted: time.Minute, kid: "ec-1"},
+		{name: "unknown key refetches", served: known, cached: []jwk{}, cachedAge: time.Minute, attempted: 2 * jwksMinRefresh, kid: "ec-1", wantFetch: true},
+		{name: "unknown key rate limited", served: known, cached: []jwk{}, cachedAge: time.Second, attempted: time.Second, kid: "ec-1", wantErr: true},
+		{name: "provider down", served: nil, kid: "ec-1", wantErr: true, wantFetch: true},
+		{name: "stale key kept while the provider is down", served: nil, cached: known, cachedAge: 2 * jwksMaxAge, attempted: 2 * jwksMaxAge, kid: "ec-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fetches := jwksServer(t, func() []jwk { return tt.served })
+			j := NewJWKS(srv.URL)
+			if tt.cached != nil {
+				j.keys = make(map[string]any)
+				for _, k := range tt.cached {
+					key, err := k.publicKey()
+					if err != nil {
+						t.Fatal(err)
+					}
+					j.keys[k.Kid] = key
+				}
+				j.fetchedAt = time.Now().Add(-tt.cachedAge)
+			}
+			if tt.attempted > 0 {
+				j.attemptedAt = time.Now().Add(-tt.attempted)
+			}
+
+			key, err := j.Key(context.Background(), tt.kid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Key() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !ecKey.PublicKey.Equal(key) {
+				t.Errorf("Key() = %v, want the served key", key)
+			}
+			if got := fetches.Load() > 0; got != tt.wantFetch {
+				t.Errorf("fetched = %v, want %v", got, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestJWKSRefreshesStaleKeysInBackground(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	srv, fetches := jwksServer(t, func() []jwk {
+		<-release
+		return []jwk{ecJWK("ec-1", &ecKey.PublicKey)}
+	})
+
+	j := NewJWKS(srv.URL)
+	stale, _ := ecJWK("ec-1", &ecKey.PublicKey).publicKey()
+	j.keys = map[string]any{"ec-1": stale}
+	j.fetchedAt = time.Now().Add(-2 * jwksMaxAge)
+
+	// The stale key is served while the provider is still answering
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := j.Key(context.Background(), "ec-1"); err != nil {
+				t.Errorf("Key() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j.mu.Lock()
+		refreshed := time.Since(j.fetchedAt) < jwksMaxAge
+		j.mu.Unlock()
+		if refreshed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("keys were not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want once", n)
+	}
+}
+
+func TestJWKSUnknownKeyWaitsForOneFetch(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, fetches := jwksServer(t, func() []jwk {
+		time.Sleep(20 * time.Millisecond)
+		return []jwk{ecJWK("ec-1", &ecKey.PublicKey)}
+	})
+	j := NewJWKS(srv.URL)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := j.Key(context.Background(), "ec-1"); err != nil {
+				t.Errorf("Key() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want once", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := j.Key(ctx, "ec-1"); err != nil {
+		t.Errorf("Key() of a cached key with a canceled context: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package product_clients
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen fails calls without reaching the products service while it
+// is considered down.
+var errCircuitOpen = errors.New("products service unavailable: circuit open")
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// breaker opens after threshold consecutive failed calls and fails every
+// call for cooldown. It then lets one probe call through, which closes it on
+// success or opens it again on failure.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+}
+
+// allow reports whether a call may go ahead. Every allowed call must be
+// followed by done.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// done records the outcome of an allowed call. Calls that failed for
+// reasons unrelated to the health of the products service, such as a
+// canceled request, leave the counters as they are.
+func (b *breaker) done(outcome string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.state == CircuitHalfOpen
+	if probe {
+		b.probing = false
+	}
+
+	switch outcome {
+	case "timeout", "network", "unavailable", "unexpected":
+		b.failures++
+		if probe || b.failures >= b.threshold {
+			b.state = CircuitOpen
+			b.openedAt = time.Now()
+		}
+	case "canceled":
+	default:
+		b.failures = 0
+		b.state = CircuitClosed
+	}
+}
+
+// State returns closed, open or half_open.
+func (b *breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,254 @@
+package product_clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MosaabBleik/inventory-service/internal/config"
+)
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		// cooled moves the breaker past its cooldown before the call
+		cooled    bool
+		wantAllow bool
+		// outcome is reported when the call is allowed, none if empty
+		outcome   string
+		wantState string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed below the threshold",
+			steps: []step{
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "network", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "ok", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "unavailable", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "unexpected", wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "opens at the threshold and fails fast",
+			steps: []step{
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "unavailable", wantState: CircuitOpen},
+				{wantAllow: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "client side failures do not count",
+			steps: []step{
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "canceled", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "canceled", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "not_found", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "half open lets one probe through",
+			steps: []step{
+				{wantAllow: true, outcome: "network", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "network", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "network", wantState: CircuitOpen},
+				{cooled: true, wantAllow: true, wantState: CircuitHalfOpen},
+				{wantAllow: false, wantState: CircuitHalfOpen},
+			},
+		},
+		{
+			name: "failed probe opens again",
+			steps: []step{
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitOpen},
+				{cooled: true, wantAllow: true, outcome: "timeout", wantState: CircuitOpen},
+				{wantAllow: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "probe then closed",
+			steps: []step{
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitOpen},
+				{cooled: true, wantAllow: true, outcome: "ok", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "canceled probe lets another through",
+			steps: []step{
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitClosed},
+				{wantAllow: true, outcome: "timeout", wantState: CircuitOpen},
+				{cooled: true, wantAllow: true, outcome: "canceled", wantState: CircuitHalfOpen},
+				{wantAllow: true, outcome: "ok", wantState: CircuitClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(3, time.Minute)
+			for i, s := range tt.steps {
+				if s.cooled {
+					b.mu.Lock()
+					b.openedAt = b.openedAt.Add(-b.cooldown)
+					b.mu.Unlock()
+				}
+
+				allowed := b.allow()
+				if allowed != s.wantAllow {
+					t.Fatalf("step %d: allow() = %v, want %v", i, allowed, s.wantAllow)
+				}
+				if allowed && s.outcome != "" {
+					b.done(s.outcome)
+				}
+				if state := b.State(); state != s.wantState {
+					t.Fatalf("step %d: State() = %q, want %q", i, state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		want   string
+	}{
+		{0, nil, "ok"},
+		{0, errNotFound, "not_found"},
+		{5, errCircuitOpen, "circuit_open"},
+		{0, fmt.Errorf("get product: %w", context.DeadlineExceeded), "timeout"},
+		{0, context.Canceled, "canceled"},
+		{5, errors.New("status 503"), "unavailable"},
+		{3, errors.New("bad json"), "decode"},
+		{1, errors.New("bad url"), "request"},
+		{4, errors.New("status 418"), "unexpected"},
+	}
+
+	for _, tt := range tests {
+		if got := errorClass(tt.status, tt.err); got != tt.want {
+			t.Errorf("errorClass(%d, %v) = %q, want %q", tt.status, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCallOutcome(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	callExpired, cancel := WithCallTimeout(context.Background(), -time.Second)
+	defer cancel()
+	callUnderExpired, cancel := WithCallTimeout(expired, time.Minute)
+	defer cancel()
+
+	timeout := fmt.Errorf("request timed out: %w", context.DeadlineExceeded)
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		err    error
+		want   string
+	}{
+		{"ok", context.Background(), 0, nil, "ok"},
+		{"client timeout", context.Background(), 2, timeout, "timeout"},
+		{"call timeout", callExpired, 2, timeout, "timeout"},
+		{"caller deadline", expired, 2, timeout, "canceled"},
+		{"caller deadline under a call timeout", callUnderExpired, 2, timeout, "canceled"},
+		{"caller deadline does not hide other failures", expired, 5, errors.New("status 503"), "unavailable"},
+	}
+
+	for _, tt := range tests {
+		if got := callOutcome(tt.ctx, tt.status, tt.err); got != tt.want {
+			t.Errorf("%s: callOutcome() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerCountsOnlyUpstreamTimeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	const short = 20 * time.Millisecond
+	tests := []struct {
+		name string
+		// clientTimeout is the timeout of the HTTP client
+		clientTimeout time.Duration
+		ctx           func() (context.Context, context.CancelFunc)
+		wantState     string
+	}{
+		{
+			name:          "caller deadline",
+			clientTimeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), short)
+			},
+			wantState: CircuitClosed,
+		},
+		{
+			name:          "caller deadline under a call timeout",
+			clientTimeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				caller, cancel := context.WithTimeout(context.Background(), short)
+				ctx, cancelCall := WithCallTimeout(caller, time.Minute)
+				return ctx, func() { cancelCall(); cancel() }
+			},
+			wantState: CircuitClosed,
+		},
+		{
+			name:          "call timeout",
+			clientTimeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return WithCallTimeout(context.Background(), short)
+			},
+			wantState: CircuitOpen,
+		},
+		{
+			name:          "client timeout",
+			clientTimeout: short,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			wantState: CircuitOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewProductsClient(config.Products{
+				URL:              srv.URL,
+				Timeout:          tt.clientTimeout,
+				BreakerThreshold: 1,
+				BreakerCooldown:  time.Minute,
+			})
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			if _, _, err := c.GetProduct(ctx, "p1"); err == nil {
+				t.Fatal("GetProduct() succeeded, want a timeout")
+			}
+			if state := c.CircuitState(); state != tt.wantState {
+				t.Errorf("CircuitState() = %q, want %q", state, tt.wantState)
+			}
+		})
+	}
+}
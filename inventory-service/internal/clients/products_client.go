@@ -7,16 +7,53 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 	// "strconv"
 	// "strings"
+
+	"github.com/MosaabBleik/inventory-service/internal/config"
+	"github.com/MosaabBleik/inventory-service/internal/metrics"
+	"github.com/MosaabBleik/inventory-service/internal/middleware"
+	"github.com/MosaabBleik/inventory-service/internal/tenant"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	errNotFound = errors.New("not found")
+	errRejected = errors.New("products service rejected the inventory service credentials")
 )
 
+// maxCachedResponses bounds the revalidation cache.
+const maxCachedResponses = 1000
+
+var tracer = otel.Tracer("github.com/MosaabBleik/inventory-service/internal/clients")
+
 type ProductsClient struct {
 	baseURL    string
 	httpClient *http.Client
+	// apiKey is the credential of the inventory service, sent as X-API-Key
+	apiKey  string
+	breaker *breaker
+
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+// cachedResponse is a previous 200 response kept to revalidate with
+// If-None-Match and If-Modified-Since.
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	body         []byte
 }
 
 type Product struct {
@@ -24,59 +61,319 @@ type Product struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description,omitempty"`
 	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
 	Category    string  `json:"category"`
+	Status      string  `json:"status"`
+}
+
+// Variant is a SKU of a product as resolved by the products service.
+type Variant struct {
+	ID            string         `json:"id"`
+	ProductID     string         `json:"product_id"`
+	ProductStatus string         `json:"product_status"`
+	SKU           string         `json:"sku"`
+	Options       map[string]any `json:"options"`
+	Barcode       *string        `json:"barcode"`
+	Price         float64        `json:"price"`
+	Currency      string         `json:"currency"`
+}
+
+// callerKey holds the context a call timeout was applied to
+type callerKey struct{}
+
+// WithCallTimeout bounds the products service calls made with the returned
+// context by d. Running out of d counts as a failure of the products service,
+// unlike the caller's own context ending, e.g. when its request times out.
+func WithCallTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	bounded, cancel := context.WithTimeout(ctx, d)
+	return context.WithValue(bounded, callerKey{}, ctx), cancel
+}
+
+// callerContext returns the context of the caller, before any call timeout.
+func callerContext(ctx context.Context) context.Context {
+	if caller, ok := ctx.Value(callerKey{}).(context.Context); ok {
+		return caller
+	}
+	return ctx
 }
 
-func NewProductsClient(baseURL string, seconds int) *ProductsClient {
+func NewProductsClient(c config.Products) *ProductsClient {
+	// The transport traces calls and sends the traceparent header
 	return &ProductsClient{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: time.Duration(seconds) * time.Second},
+		baseURL: c.URL,
+		httpClient: &http.Client{
+			Timeout:   c.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		apiKey:  c.APIKey,
+		breaker: newBreaker(c.BreakerThreshold, c.BreakerCooldown),
+		cache:   make(map[string]cachedResponse),
+	}
+}
+
+// CircuitState returns the state of the circuit breaker guarding calls to
+// the products service: closed, open or half_open.
+func (c *ProductsClient) CircuitState() string {
+	return c.breaker.State()
+}
+
+// Ping checks that the products service answers its liveness probe. It
+// bypasses the circuit breaker, so it also tells when an open circuit could
+// close again.
+func (c *ProductsClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/livez", nil)
+	if err != nil {
+		return err
 	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from products service: %s", resp.Status)
+	}
+	return nil
 }
 
 func (c *ProductsClient) GetProduct(ctx context.Context, productID string) (*Product, int, error) {
+	ctx, span := tracer.Start(ctx, "ProductsClient.GetProduct", trace.WithAttributes(attribute.String("product.id", productID)))
+	defer span.End()
+
 	url := fmt.Sprintf("%s/api/products/%s", c.baseURL, productID)
+
+	var product Product
+	status, err := c.get(ctx, "product", url, &product)
+	if errors.Is(err, errNotFound) {
+		return nil, 4, fmt.Errorf("product not found (id=%s)", productID)
+	}
+	if err != nil {
+		return nil, status, err
+	}
+	return &product, 0, nil
+}
+
+// GetVariant resolves a variant ID to its SKU and parent product.
+func (c *ProductsClient) GetVariant(ctx context.Context, variantID string) (*Variant, int, error) {
+	ctx, span := tracer.Start(ctx, "ProductsClient.GetVariant", trace.WithAttributes(attribute.String("variant.id", variantID)))
+	defer span.End()
+
+	url := fmt.Sprintf("%s/api/variants/%s", c.baseURL, variantID)
+
+	var variant Variant
+	status, err := c.get(ctx, "variant", url, &variant)
+	if errors.Is(err, errNotFound) {
+		return nil, 4, fmt.Errorf("variant not found (id=%s)", variantID)
+	}
+	if err != nil {
+		return nil, status, err
+	}
+	return &variant, 0, nil
+}
+
+// get calls fetch through the circuit breaker and records the latency and
+// outcome of the call under endpoint, and on the span of ctx.
+func (c *ProductsClient) get(ctx context.Context, endpoint, url string, out any) (int, error) {
+	start := time.Now()
+
+	var status int
+	var err error
+	if c.breaker.allow() {
+		status, err = c.fetch(ctx, url, out)
+		c.breaker.done(callOutcome(ctx, status, err))
+	} else {
+		status, err = 5, errCircuitOpen
+	}
+	metrics.UpstreamCircuitOpen.Set(boolGauge(c.breaker.State() == CircuitOpen))
+
+	outcome := callOutcome(ctx, status, err)
+	metrics.UpstreamDuration.WithLabelValues(endpoint, outcome).Observe(time.Since(start).Seconds())
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("products_client.outcome", outcome))
+	if err != nil {
+		metrics.UpstreamErrors.WithLabelValues(endpoint, outcome).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, outcome)
+	}
+	return status, err
+}
+
+// callOutcome is the errorClass of a call made with ctx. A timeout is only
+// blamed on the products service when it came from a call timeout or the
+// HTTP client: once the caller's own context has ended, the call counts as
+// canceled.
+func callOutcome(ctx context.Context, status int, err error) string {
+	outcome := errorClass(status, err)
+	if outcome == "timeout" && callerContext(ctx).Err() != nil {
+		return "canceled"
+	}
+	return outcome
+}
+
+// errorClass names the kind of failure of a call, ok if it succeeded.
+func errorClass(status int, err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, errNotFound):
+		return "not_found"
+	case errors.Is(err, errCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, errRejected):
+		return "rejected"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case status == 5:
+		return "unavailable"
+	case status == 3:
+		return "decode"
+	case status == 1:
+		return "request"
+	}
+	return "unexpected"
+}
+
+// fetch performs a GET request on behalf of the tenant of ctx and decodes the
+// JSON body into out. The returned status classifies failures the same way
+// for every endpoint.
+func (c *ProductsClient) fetch(ctx context.Context, url string, out any) (int, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, 1, fmt.Errorf("failed to create request: %v", err)
+		return 1, fmt.Errorf("failed to create request: %v", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	tenantID := tenant.FromContext(ctx)
+	req.Header.Set(tenant.Header, tenantID)
+	if id := middleware.RequestIDFrom(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+
+	// Responses differ per tenant
+	cacheKey := tenantID + " " + url
+	cached, hasCached := c.cached(cacheKey)
+	if hasCached {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			return nil, 2, fmt.Errorf("request timed out: %w", err)
+			return 2, fmt.Errorf("request timed out: %w", err)
 		}
 		if errors.Is(err, context.Canceled) {
-			return nil, 3, fmt.Errorf("request canceled: %w", err)
+			return 3, fmt.Errorf("request canceled: %w", err)
 		}
 
 		var netErr net.Error
 		if errors.As(err, &netErr) {
 			if netErr.Timeout() {
-				return nil, 4, fmt.Errorf("network timeout: %w", err)
+				return 4, fmt.Errorf("network timeout: %w", err)
 			}
-			return nil, 5, fmt.Errorf("network error: %w", err)
+			return 5, fmt.Errorf("network error: %w", err)
 		}
 
-		return nil, 6, fmt.Errorf("failed to reach products service: %w", err)
+		return 6, fmt.Errorf("failed to reach products service: %w", err)
 	}
 	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		var product Product
-		if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
-			return nil, 3, fmt.Errorf("failed to decode product: %v", err)
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 3, fmt.Errorf("failed to read response: %v", err)
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return 3, fmt.Errorf("failed to decode response: %v", err)
 		}
-		return &product, 0, nil
+		c.store(cacheKey, resp, body)
+		return 0, nil
+
+	case http.StatusNotModified:
+		if !hasCached {
+			return 6, fmt.Errorf("unexpected response from products service: %s", resp.Status)
+		}
+		if err := json.Unmarshal(cached.body, out); err != nil {
+			return 3, fmt.Errorf("failed to decode cached response: %v", err)
+		}
+		return 0, nil
 
 	case http.StatusNotFound:
-		return nil, 4, fmt.Errorf("product not found (id=%s)", productID)
+		c.forget(cacheKey)
+		return 4, errNotFound
 
 	case http.StatusGatewayTimeout, http.StatusServiceUnavailable:
-		return nil, 5, fmt.Errorf("products service unavailable")
+		return 5, fmt.Errorf("products service unavailable")
+
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return 6, fmt.Errorf("%w: %s", errRejected, resp.Status)
 
 	default:
-		return nil, 6, fmt.Errorf("unexpected response from products service: %s", resp.Status)
+		return 6, fmt.Errorf("unexpected response from products service: %s", resp.Status)
+	}
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (c *ProductsClient) cached(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.cache[key]
+	return cached, ok
+}
+
+// store keeps a response for revalidation if it carries a validator and
+// may be stored.
+func (c *ProductsClient) store(key string, resp *http.Response, body []byte) {
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	noStore := strings.Contains(resp.Header.Get("Cache-Control"), "no-store")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if noStore || (etag == "" && lastModified == "") {
+		delete(c.cache, key)
+		return
 	}
+
+	// Evict an arbitrary entry once full
+	if _, ok := c.cache[key]; !ok && len(c.cache) >= maxCachedResponses {
+		for key := range c.cache {
+			delete(c.cache, key)
+			break
+		}
+	}
+	c.cache[key] = cachedResponse{etag: etag, lastModified: lastModified, body: body}
+}
+
+func (c *ProductsClient) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.cache, key)
 }
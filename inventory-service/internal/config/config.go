@@ -0,0 +1,144 @@
+package config
+
+import (
+	"log/slog"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// Config is the configuration of the inventory service. Every setting has a
+// YAML key and an environment variable, see Load.
+type Config struct {
+	Server         Server        `yaml:"server"`
+	Log            Log           `yaml:"log"`
+	Database       Database      `yaml:"database"`
+	Auth           Auth          `yaml:"auth"`
+	Products       Products      `yaml:"products"`
+	Handlers       Handlers      `yaml:"handlers"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL"`
+}
+
+type Server struct {
+	Port              int           `yaml:"port" env:"PORT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type Log struct {
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+type Database struct {
+	URL            string `yaml:"url" env:"DATABASE_URL" secret:"url"`
+	MigrateOnStart bool   `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+}
+
+type Auth struct {
+	JWTSecret        string `yaml:"jwt_secret" env:"AUTH_JWT_SECRET" secret:"true"`
+	JWTPublicKeyFile string `yaml:"jwt_public_key_file" env:"AUTH_JWT_PUBLIC_KEY_FILE"`
+	JWKSURL          string `yaml:"jwks_url" env:"AUTH_JWKS_URL"`
+	JWTIssuer        string `yaml:"jwt_issuer" env:"AUTH_JWT_ISSUER"`
+	JWTAudience      string `yaml:"jwt_audience" env:"AUTH_JWT_AUDIENCE"`
+	RolesClaim       string `yaml:"roles_claim" env:"AUTH_ROLES_CLAIM"`
+	TenantClaim      string `yaml:"tenant_claim" env:"AUTH_TENANT_CLAIM"`
+	APIKeys          string `yaml:"api_keys" env:"AUTH_API_KEYS" secret:"true"`
+	Disabled         bool   `yaml:"disabled" env:"AUTH_DISABLED"`
+}
+
+// Products is how the products service is called.
+type Products struct {
+	URL    string `yaml:"url" env:"PRODUCTS_SERVICE_URL"`
+	APIKey string `yaml:"api_key" env:"PRODUCTS_API_KEY" secret:"true"`
+	// Timeout bounds every HTTP call to the products service
+	Timeout time.Duration `yaml:"timeout" env:"REQUEST_TIMEOUT"`
+	// The circuit opens after BreakerThreshold consecutive failed calls and
+	// fails calls for BreakerCooldown before letting one through again
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"PRODUCTS_BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"PRODUCTS_BREAKER_COOLDOWN"`
+}
+
+type Handlers struct {
+	// Timeout bounds the work of a request, lookups and queries included.
+	// Stock reads and availability checks have their own bounds.
+	Timeout             time.Duration `yaml:"timeout" env:"HANDLER_TIMEOUT"`
+	ReadTimeout         time.Duration `yaml:"read_timeout" env:"HANDLER_READ_TIMEOUT"`
+	AvailabilityTimeout time.Duration `yaml:"availability_timeout" env:"HANDLER_AVAILABILITY_TIMEOUT"`
+	// MaxBodyBytes bounds the body of a request
+	MaxBodyBytes int `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+	// ItemTimeout bounds the product lookup of each item of an availability
+	// check
+	ItemTimeout time.Duration `yaml:"item_timeout" env:"AVAILABILITY_ITEM_TIMEOUT"`
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: Server{
+			Port:              8081,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log:      Log{Level: "info"},
+		Database: Database{MigrateOnStart: true},
+		Auth:     Auth{RolesClaim: "roles", TenantClaim: "tenant_id"},
+		Products: Products{
+			URL:              "http://localhost:8080",
+			Timeout:          5 * time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+		},
+		Handlers: Handlers{
+			Timeout:             5 * time.Second,
+			ReadTimeout:         3 * time.Second,
+			AvailabilityTimeout: 10 * time.Second,
+			MaxBodyBytes:        1 << 20,
+			ItemTimeout:         3 * time.Second,
+		},
+		IdempotencyTTL: 24 * time.Hour,
+	}
+}
+
+func (c *Config) validate() []error {
+	var errs check
+	errs.failIf(c.Server.Port < 1 || c.Server.Port > 65535, "PORT must be between 1 and 65535, got %d", c.Server.Port)
+	errs.failIf(c.Server.ReadHeaderTimeout <= 0, "HTTP_READ_HEADER_TIMEOUT must be positive")
+	errs.failIf(c.Server.ReadTimeout <= 0, "HTTP_READ_TIMEOUT must be positive")
+	errs.failIf(c.Server.WriteTimeout <= 0, "HTTP_WRITE_TIMEOUT must be positive")
+	errs.failIf(c.Server.IdleTimeout <= 0, "HTTP_IDLE_TIMEOUT must be positive")
+	errs.failIf(c.Server.ShutdownTimeout <= 0, "SHUTDOWN_TIMEOUT must be positive")
+
+	errs = append(errs, c.validateDatabase()...)
+
+	u, err := url.Parse(c.Products.URL)
+	errs.failIf(err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "", "PRODUCTS_SERVICE_URL must be an http(s) URL, got %q", c.Products.URL)
+	errs.failIf(c.Products.Timeout <= 0, "REQUEST_TIMEOUT must be positive")
+	errs.failIf(c.Products.BreakerThreshold <= 0, "PRODUCTS_BREAKER_THRESHOLD must be positive")
+	errs.failIf(c.Products.BreakerCooldown <= 0, "PRODUCTS_BREAKER_COOLDOWN must be positive")
+	errs.failIf(c.Handlers.Timeout <= 0, "HANDLER_TIMEOUT must be positive")
+	errs.failIf(c.Handlers.ReadTimeout <= 0, "HANDLER_READ_TIMEOUT must be positive")
+	errs.failIf(c.Handlers.AvailabilityTimeout <= 0, "HANDLER_AVAILABILITY_TIMEOUT must be positive")
+	errs.failIf(c.Handlers.MaxBodyBytes <= 0, "MAX_BODY_BYTES must be positive")
+	errs.failIf(c.Handlers.ItemTimeout <= 0, "AVAILABILITY_ITEM_TIMEOUT must be positive")
+	errs.failIf(c.IdempotencyTTL <= 0, "IDEMPOTENCY_TTL must be positive")
+	return errs
+}
+
+// validateDatabase checks the settings the migrate subcommand uses.
+func (c *Config) validateDatabase() []error {
+	var errs check
+	var level slog.Level
+	errs.failIf(level.UnmarshalText([]byte(c.Log.Level)) != nil, "LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	errs.failIf(c.Database.URL == "", "DATABASE_URL is required")
+	return errs
+}
+
+// Redacted returns the effective configuration with secrets hidden.
+func (c *Config) Redacted() map[string]any {
+	return redact(reflect.ValueOf(c).Elem())
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads for the duration of the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+
+	var clear func(reflect.Type)
+	clear = func(typ reflect.Type) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			if field.Type.Kind() == reflect.Struct {
+				clear(field.Type)
+				continue
+			}
+			if name := field.Tag.Get("env"); name != "" {
+				t.Setenv(name, "")
+			}
+		}
+	}
+	clear(reflect.TypeOf(Config{}))
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		file  string
+		check func(t *testing.T, cfg *Config)
+		// wantErrs are parts of the error, all reported at once
+		wantErrs []string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{"DATABASE_URL": "postgres://localhost/inventory"},
+			check: func(t *testing.T, cfg *Config) {
+				want := defaults()
+				want.Database.URL = "postgres://localhost/inventory"
+				if !reflect.DeepEqual(cfg, want) {
+					t.Errorf("Load() = %+v, want %+v", cfg, want)
+				}
+			},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"DATABASE_URL":                 "postgres://localhost/inventory",
+				"PRODUCTS_SERVICE_URL":         "https://products.internal",
+				"PRODUCTS_BREAKER_THRESHOLD":   "2",
+				"PRODUCTS_BREAKER_COOLDOWN":    "90",
+				"HANDLER_AVAILABILITY_TIMEOUT": "1m",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Products.URL != "https://products.internal" {
+					t.Errorf("Products.URL = %q, want https://products.internal", cfg.Products.URL)
+				}
+				if cfg.Products.BreakerThreshold != 2 {
+					t.Errorf("Products.BreakerThreshold = %d, want 2", cfg.Products.BreakerThreshold)
+				}
+				if cfg.Products.BreakerCooldown != 90*time.Second {
+					t.Errorf("Products.BreakerCooldown = %v, want 90s", cfg.Products.BreakerCooldown)
+				}
+				if cfg.Handlers.AvailabilityTimeout != time.Minute {
+					t.Errorf("Handlers.AvailabilityTimeout = %v, want 1m", cfg.Handlers.AvailabilityTimeout)
+				}
+			},
+		},
+		{
+			name: "file under environment",
+			env:  map[string]string{"REQUEST_TIMEOUT": "2s"},
+			file: "products:\n  timeout: 8s\n  breaker_threshold: 7\ndatabase:\n  url: postgres://db/inventory\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Products.Timeout != 2*time.Second {
+					t.Errorf("Products.Timeout = %v, want 2s from the environment", cfg.Products.Timeout)
+				}
+				if cfg.Products.BreakerThreshold != 7 {
+					t.Errorf("Products.BreakerThreshold = %d, want 7 from the file", cfg.Products.BreakerThreshold)
+				}
+			},
+		},
+		{
+			name:     "unknown file key",
+			file:     "products:\n  urll: http://products\n",
+			wantErrs: []string{"CONFIG_FILE", "urll"},
+		},
+		{
+			name: "unparsable values",
+			env: map[string]string{
+				"DATABASE_URL":               "postgres://localhost/inventory",
+				"PRODUCTS_BREAKER_THRESHOLD": "many",
+				"REQUEST_TIMEOUT":            "5 seconds",
+			},
+			wantErrs: []string{
+				`PRODUCTS_BREAKER_THRESHOLD: invalid integer "many"`,
+				`REQUEST_TIMEOUT: invalid duration "5 seconds"`,
+			},
+		},
+		{
+			name: "invalid values",
+			env: map[string]string{
+				"PORT":                       "0",
+				"PRODUCTS_SERVICE_URL":       "products:8080",
+				"PRODUCTS_BREAKER_THRESHOLD": "0",
+				"AVAILABILITY_ITEM_TIMEOUT":  "-1",
+				"IDEMPOTENCY_TTL":            "-1h",
+			},
+			wantErrs: []string{
+				"PORT must be between 1 and 65535, got 0",
+				"DATABASE_URL is required",
+				`PRODUCTS_SERVICE_URL must be an http(s) URL, got "products:8080"`,
+				"PRODUCTS_BREAKER_THRESHOLD must be positive",
+				"AVAILABILITY_ITEM_TIMEOUT must be positive",
+				"IDEMPOTENCY_TTL must be positive",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			cfg, err := Load()
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("Load() succeeded, want errors %q", tt.wantErrs)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Load() error = %q, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := defaults()
+	cfg.Database.URL = "host=db user=inventory password=s3cret dbname=inventory"
+	cfg.Products.APIKey = "inventory-key"
+
+	got := cfg.Redacted()
+
+	tests := []struct {
+		path []string
+		want any
+	}{
+		{[]string{"database", "url"}, redacted},
+		{[]string{"products", "api_key"}, redacted},
+		{[]string{"products", "url"}, "http://localhost:8080"},
+		// Unset secrets show they are unset
+		{[]string{"auth", "jwt_secret"}, ""},
+		{[]string{"products", "breaker_threshold"}, 5},
+		{[]string{"products", "breaker_cooldown"}, "30s"},
+	}
+
+	for _, tt := range tests {
+		var v any = got
+		for _, key := range tt.path {
+			v = v.(map[string]any)[key]
+		}
+		if v != tt.want {
+			t.Errorf("Redacted()[%s] = %#v, want %#v", strings.Join(tt.path, "."), v, tt.want)
+		}
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		file    string
+		wantURL string
+		wantErr string
+	}{
+		{
+			name: "other settings are ignored",
+			env: map[string]string{
+				"DATABASE_URL":     "postgres://localhost/inventory",
+				"PORT":             "http",
+				"SHUTDOWN_TIMEOUT": "-1s",
+			},
+			wantURL: "postgres://localhost/inventory",
+		},
+		{
+			name:    "database from the file",
+			file:    "server:\n  port: 0\ndatabase:\n  url: postgres://db/inventory\n",
+			wantURL: "postgres://db/inventory",
+		},
+		{
+			name:    "missing database",
+			env:     map[string]string{"PORT": "9090"},
+			wantErr: "DATABASE_URL is required",
+		},
+		{
+			name:    "invalid log level",
+			env:     map[string]string{"DATABASE_URL": "postgres://localhost/inventory", "LOG_LEVEL": "loud"},
+			wantErr: "LOG_LEVEL must be debug, info, warn or error",
+		},
+		{
+			name:    "unparsable database setting",
+			env:     map[string]string{"DATABASE_URL": "postgres://localhost/inventory", "MIGRATE_ON_START": "maybe"},
+			wantErr: `MIGRATE_ON_START: invalid boolean "maybe"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			cfg, err := LoadDatabase()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadDatabase() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDatabase() error = %v", err)
+			}
+			if cfg.Database.URL != tt.wantURL {
+				t.Errorf("Database.URL = %q, want %q", cfg.Database.URL, tt.wantURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the value of a secret that is set
+const redacted = "[REDACTED]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load builds the configuration from, in increasing precedence, the
+// defaults, the YAML file named by CONFIG_FILE, a .env file and the
+// environment. Every invalid value is reported at once.
+func Load() (*Config, error) {
+	cfg, err := loadSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	loadEnv(reflect.ValueOf(cfg).Elem(), &errs)
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// LoadDatabase builds the configuration like Load, but reads and validates
+// only the database and log settings. The migrate subcommand needs nothing
+// else, so a migration job does not have to configure the whole server.
+func LoadDatabase() (*Config, error) {
+	cfg, err := loadSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	loadEnv(reflect.ValueOf(&cfg.Database).Elem(), &errs)
+	loadEnv(reflect.ValueOf(&cfg.Log).Elem(), &errs)
+	errs = append(errs, cfg.validateDatabase()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// loadSources returns the defaults overlaid with CONFIG_FILE, after loading
+// .env into the environment.
+func loadSources() (*Config, error) {
+	cfg := defaults()
+
+	// Variables already set take precedence over .env
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// loadFile reads a YAML file over cfg. Unknown keys are rejected so typos
+// do not go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("CONFIG_FILE %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv sets every field tagged with env from the variable of that name,
+// when it is not empty.
+func loadEnv(v reflect.Value, errs *[]error) {
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			loadEnv(value, errs)
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		raw := strings.TrimSpace(os.Getenv(name))
+		if name == "" || raw == "" {
+			continue
+		}
+		if err := setValue(value, raw); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := parseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseDuration reads a duration such as 30s or 1m, a bare number is seconds.
+func parseDuration(raw string) (time.Duration, error) {
+	if n, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, use e.g. 30s or 1m", raw)
+	}
+	return d, nil
+}
+
+// redact returns v keyed by YAML names, with the value of fields tagged
+// secret:"true" hidden and the password of fields tagged secret:"url"
+// masked.
+func redact(v reflect.Value) map[string]any {
+	out := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			out[key] = redact(value)
+		case field.Type == durationType:
+			out[key] = time.Duration(value.Int()).String()
+		case value.IsZero():
+			out[key] = value.Interface()
+		case field.Tag.Get("secret") == "true":
+			out[key] = redacted
+		case field.Tag.Get("secret") == "url":
+			out[key] = redactURL(value.String())
+		default:
+			out[key] = value.Interface()
+		}
+	}
+	return out
+}
+
+// redactURL masks the password of a URL. Anything else, such as a key=value
+// connection string, is hidden as a whole.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", "xxxxx")
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+// check collects the problems found by validate.
+type check []error
+
+func (c *check) failIf(failed bool, format string, args ...any) {
+	if failed {
+		*c = append(*c, fmt.Errorf(format, args...))
+	}
+}
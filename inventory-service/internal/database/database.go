@@ -2,15 +2,14 @@ package database
 
 import (
 	"log"
-	"os"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-func Connect() *gorm.DB {
-	dsn := os.Getenv("DATABASE_URL")
-
+// Connect opens the database at dsn.
+func Connect(dsn string) *gorm.DB {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		PrepareStmt: true,
 	})
@@ -19,5 +18,19 @@ func Connect() *gorm.DB {
 		log.Fatal("Failed to connect to DB:", err)
 	}
 
+	// Queries run with a request context join its trace
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+		log.Fatal("Failed to instrument DB:", err)
+	}
+
 	return db
 }
+
+// Close closes the connections of db.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MosaabBleik/inventory-service/internal/middleware"
+	"github.com/MosaabBleik/inventory-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyStore keeps idempotency records in Postgres.
+type IdempotencyStore struct {
+	DB *gorm.DB
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, key string, rec middleware.IdempotencyRecord, ttl time.Duration) (middleware.IdempotencyRecord, bool, error) {
+	row := models.IdempotencyKey{
+		Key:         key,
+		Fingerprint: rec.Fingerprint,
+		Header:      "{}",
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+
+	var existing models.IdempotencyKey
+	reserved := false
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// An expired record frees its key
+		err := tx.Where("key = ? AND expires_at < ?", key, time.Now()).Delete(&models.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			reserved = true
+			return nil
+		}
+		return tx.Where("key = ?", key).First(&existing).Error
+	})
+	if err != nil || reserved {
+		return middleware.IdempotencyRecord{}, reserved, err
+	}
+
+	var header http.Header
+	if err := json.Unmarshal([]byte(existing.Header), &header); err != nil {
+		return middleware.IdempotencyRecord{}, false, err
+	}
+	return middleware.IdempotencyRecord{
+		Fingerprint: existing.Fingerprint,
+		Status:      existing.Status,
+		Header:      header,
+		Body:        existing.Body,
+	}, false, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, rec middleware.IdempotencyRecord, ttl time.Duration) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.WithContext(ctx).Model(&models.IdempotencyKey{}).Where("key = ?", key).Updates(map[string]any{
+		"status":     rec.Status,
+		"header":     string(header),
+		"body":       rec.Body,
+		"expires_at": time.Now().Add(ttl),
+	}).Error
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.DB.WithContext(ctx).Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// PurgeExpired deletes records past their retention window.
+func (s *IdempotencyStore) PurgeExpired(ctx context.Context) error {
+	return s.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error
+}
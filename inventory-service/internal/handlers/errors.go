@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/MosaabBleik/inventory-service/internal/problem"
+)
+
+// writeInternalError logs err, which may hold details such as database
+// messages, and answers with message only. Work cut short by the request
+// timeout answers with a 503.
+func writeInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "error", err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		problem.Write(w, http.StatusServiceUnavailable, "request_timeout", "The request took too long and was stopped")
+		return
+	}
+	problem.Write(w, http.StatusInternalServerError, problem.Code(http.StatusInternalServerError), message)
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, http.StatusNotFound, "route_not_found", "No route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers requests to a route that does not accept their
+// method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method "+r.Method+" is not allowed on "+r.URL.Path)
+}
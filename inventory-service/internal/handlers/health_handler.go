@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	product_clients "github.com/MosaabBleik/inventory-service/internal/clients"
+)
+
+// readyTimeout bounds each dependency check of a readiness probe
+const readyTimeout = 2 * time.Second
+
+// dependencyStatus is the result of checking one dependency.
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// productsServiceStatus adds the state of the circuit breaker guarding calls
+// to the products service.
+type productsServiceStatus struct {
+	dependencyStatus
+	Circuit string `json:"circuit"`
+}
+
+func checkDependency(ctx context.Context, check func(context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := dependencyStatus{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		status.Status = "error"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Live reports that the process is up. It checks no dependency, so an
+// outage of one does not get the service restarted.
+func (h *InventoryHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Ready reports whether the database is usable, with 503 when it is not.
+// An unreachable products service or an open circuit only makes the service
+// degraded: stock can still be read and adjusted for known items, and taking
+// the inventory service out of rotation would not bring products back.
+func (h *InventoryHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	var db dependencyStatus
+	var products productsServiceStatus
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		db = checkDependency(r.Context(), func(ctx context.Context) error {
+			return h.DB.WithContext(ctx).Exec("SELECT 1").Error
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		products.dependencyStatus = checkDependency(r.Context(), h.ProductsClient.Ping)
+	}()
+	wg.Wait()
+	products.Circuit = h.ProductsClient.CircuitState()
+
+	status := "ready"
+	switch {
+	case db.Status != "ok":
+		status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case products.Status != "ok" || products.Circuit != product_clients.CircuitClosed:
+		status = "degraded"
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"checks": map[string]any{
+			"database":         db,
+			"products_service": products,
+		},
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -12,15 +13,23 @@ import (
 
 	product_clients "github.com/MosaabBleik/inventory-service/internal/clients"
 	"github.com/MosaabBleik/inventory-service/internal/models"
+	"github.com/MosaabBleik/inventory-service/internal/problem"
+	"github.com/MosaabBleik/inventory-service/internal/tenant"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+type CheckAvailabilityItem struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
 type CheckAvailabilityRequest struct {
-	Items []struct {
-		ProductID string `json:"product_id"`
-		Quantity  int    `json:"quantity"`
-	} `json:"items"`
+	Items []CheckAvailabilityItem `json:"items"`
 }
 
 type WarehouseStock struct {
@@ -30,6 +39,7 @@ type WarehouseStock struct {
 
 type ItemAvailability struct {
 	ProductID      string           `json:"product_id"`
+	VariantID      string           `json:"variant_id,omitempty"`
 	Requested      int              `json:"requested"`
 	AvailableStock int              `json:"available_stock"`
 	Status         string           `json:"status"`
@@ -42,63 +52,149 @@ type CheckAvailabilityResponse struct {
 	Items     []ItemAvailability `json:"items"`
 }
 
+type VariantStock struct {
+	VariantID     string `json:"variant_id"`
+	TotalQuantity int    `json:"total_quantity"`
+}
+
 type InventoryHandler struct {
 	DB             *gorm.DB
 	ProductsClient *product_clients.ProductsClient
+	// ItemTimeout bounds the product lookup of each item of an availability
+	// check, the request as a whole is bounded by middleware.Timeout
+	ItemTimeout time.Duration
+}
+
+var errVariantMismatch = errors.New("variant does not belong to product")
+
+var tracer = otel.Tracer("github.com/MosaabBleik/inventory-service/internal/handlers")
+
+// resolvedItem is a product, or the product of a variant, as known to the
+// products service.
+type resolvedItem struct {
+	ProductID string
+	Status    string
+}
+
+// acceptsStock reports whether the product may receive new stock.
+// Discontinued and archived products can only be sold down.
+func (item resolvedItem) acceptsStock() bool {
+	return item.Status != "discontinued" && item.Status != "archived"
+}
+
+// resolveItem checks that a product, or one of its variants, exists in the
+// products service and returns the product.
+func (h *InventoryHandler) resolveItem(ctx context.Context, productID, variantID string) (resolvedItem, int, error) {
+	if variantID == "" {
+		product, prodStatus, err := h.ProductsClient.GetProduct(ctx, productID)
+		if err != nil {
+			return resolvedItem{}, prodStatus, err
+		}
+		return resolvedItem{ProductID: productID, Status: product.Status}, 0, nil
+	}
+
+	variant, prodStatus, err := h.ProductsClient.GetVariant(ctx, variantID)
+	if err != nil {
+		return resolvedItem{}, prodStatus, err
+	}
+	if productID != "" && variant.ProductID != productID {
+		return resolvedItem{}, 0, errVariantMismatch
+	}
+
+	return resolvedItem{ProductID: variant.ProductID, Status: variant.ProductStatus}, 0, nil
+}
+
+func writeStockRefused(w http.ResponseWriter, item resolvedItem) {
+	problem.Write(w, http.StatusConflict, "product_not_active", fmt.Sprintf("product is %s and cannot receive new stock", item.Status))
+}
+
+// variantScope matches the stock of a variant, or product-level stock when
+// variantID is empty.
+func variantScope(variantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if variantID == "" {
+			return db.Where("variant_id IS NULL")
+		}
+		return db.Where("variant_id = ?", variantID)
+	}
+}
+
+// tenantScope matches the stock of the tenant ctx belongs to.
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenantID := tenant.FromContext(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
 
 func (h *InventoryHandler) AddInventory(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ProductID         string `json:"product_id"`
+		VariantID         string `json:"variant_id"`
 		WarehouseLocation string `json:"warehouse_location"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
-	_, prodStatus, err := h.ProductsClient.GetProduct(ctx, req.ProductID)
+	item, prodStatus, err := h.resolveItem(ctx, req.ProductID, req.VariantID)
+	if errors.Is(err, errVariantMismatch) {
+		problem.Write(w, http.StatusBadRequest, "variant_mismatch", err.Error())
+		return
+	}
 	if err != nil {
-		WriteProductErrorResponse(w, prodStatus, err)
+		WriteProductErrorResponse(w, r, prodStatus, err)
+		return
+	}
+	if !item.acceptsStock() {
+		writeStockRefused(w, item)
 		return
 	}
+	productID := item.ProductID
 
 	var existing models.Inventory
 	err = h.DB.WithContext(ctx).
-		Where("product_id = ? AND warehouse_location = ?", req.ProductID, req.WarehouseLocation).
+		Scopes(tenantScope(ctx), variantScope(req.VariantID)).
+		Where("product_id = ? AND warehouse_location = ?", productID, req.WarehouseLocation).
 		First(&existing).Error
 
 	if err == nil {
-		http.Error(w, "inventory already exists for this product and warehouse", http.StatusConflict)
+		problem.Write(w, http.StatusConflict, "inventory_exists", "inventory already exists for this product and warehouse")
 		return
 	}
 
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		http.Error(w, fmt.Sprintf("error checking inventory: %s", err), http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to check inventory", err)
 		return
 	}
 
 	inventory := models.Inventory{
-		ProductID:         req.ProductID,
+		TenantID:          tenant.FromContext(ctx),
+		ProductID:         productID,
+		VariantID:         optionalString(req.VariantID),
 		WarehouseLocation: req.WarehouseLocation,
 		Quantity:          0,
 	}
 
 	if err := h.DB.WithContext(ctx).Create(&inventory).Error; err != nil {
-		http.Error(w, fmt.Sprintf("Error creating inventory: %s", err), http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to create inventory", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(inventory); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to encode response", err)
 	}
 }
 
@@ -107,39 +203,47 @@ func (h *InventoryHandler) Stock(w http.ResponseWriter, r *http.Request) {
 	productID := vars["product_id"]
 
 	if productID == "" {
-		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Product ID is required")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	_, prodStatus, err := h.ProductsClient.GetProduct(ctx, productID)
 	if err != nil {
-		WriteProductErrorResponse(w, prodStatus, err)
+		WriteProductErrorResponse(w, r, prodStatus, err)
 		return
 	}
 
 	// Fetch all inventories for the given product
 	var inventories []models.Inventory
 	err = h.DB.WithContext(ctx).
+		Scopes(tenantScope(ctx)).
 		Where("product_id = ?", productID).
 		Find(&inventories).Error
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("database error: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to fetch inventory", err)
 		return
 	}
 
 	if len(inventories) == 0 {
-		http.Error(w, "no inventory records found for this product", http.StatusNotFound)
+		problem.Write(w, http.StatusNotFound, "inventory_not_found", "no inventory records found for this product")
 		return
 	}
 
-	// Calculate total quantity
+	// Calculate total quantity, and per SKU for variant stock
 	totalQuantity := 0
+	variantTotals := make(map[string]int)
+	var variantOrder []string
 	for _, inv := range inventories {
 		totalQuantity += inv.Quantity
+		if inv.VariantID != nil {
+			if _, ok := variantTotals[*inv.VariantID]; !ok {
+				variantOrder = append(variantOrder, *inv.VariantID)
+			}
+			variantTotals[*inv.VariantID] += inv.Quantity
+		}
 	}
 
 	// Response payload
@@ -149,10 +253,68 @@ func (h *InventoryHandler) Stock(w http.ResponseWriter, r *http.Request) {
 		"inventories":    inventories,
 	}
 
+	if len(variantOrder) > 0 {
+		variants := make([]VariantStock, 0, len(variantOrder))
+		for _, variantID := range variantOrder {
+			variants = append(variants, VariantStock{
+				VariantID:     variantID,
+				TotalQuantity: variantTotals[variantID],
+			})
+		}
+		response["variants"] = variants
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to encode JSON", err)
+	}
+}
+
+func (h *InventoryHandler) VariantStock(w http.ResponseWriter, r *http.Request) {
+	variantID := mux.Vars(r)["variant_id"]
+
+	ctx := r.Context()
+
+	variant, prodStatus, err := h.ProductsClient.GetVariant(ctx, variantID)
+	if err != nil {
+		WriteProductErrorResponse(w, r, prodStatus, err)
+		return
+	}
+
+	var inventories []models.Inventory
+	err = h.DB.WithContext(ctx).
+		Scopes(tenantScope(ctx)).
+		Where("product_id = ? AND variant_id = ?", variant.ProductID, variant.ID).
+		Find(&inventories).Error
+
+	if err != nil {
+		writeInternalError(w, r, "Failed to fetch inventory", err)
+		return
+	}
+
+	if len(inventories) == 0 {
+		problem.Write(w, http.StatusNotFound, "inventory_not_found", "no inventory records found for this variant")
+		return
+	}
+
+	totalQuantity := 0
+	for _, inv := range inventories {
+		totalQuantity += inv.Quantity
+	}
+
+	response := map[string]any{
+		"product_id":     variant.ProductID,
+		"variant_id":     variant.ID,
+		"sku":            variant.SKU,
+		"total_quantity": totalQuantity,
+		"inventories":    inventories,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeInternalError(w, r, "Failed to encode JSON", err)
 	}
 }
 
@@ -161,53 +323,60 @@ func (h *InventoryHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	productID := vars["product_id"]
 
 	if productID == "" {
-		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Product ID is required")
 		return
 	}
 
 	var req struct {
 		Quantity          int    `json:"quantity"`
+		VariantID         string `json:"variant_id"`
 		WarehouseLocation string `json:"warehouse_location"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
 		return
 	}
 
 	if req.WarehouseLocation == "" {
-		http.Error(w, "warehouse_location is required", http.StatusBadRequest)
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "warehouse_location is required")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
-	_, prodStatus, err := h.ProductsClient.GetProduct(ctx, productID)
+	item, prodStatus, err := h.resolveItem(ctx, productID, req.VariantID)
+	if errors.Is(err, errVariantMismatch) {
+		problem.Write(w, http.StatusBadRequest, "variant_mismatch", err.Error())
+		return
+	}
 	if err != nil {
-		WriteProductErrorResponse(w, prodStatus, err)
+		WriteProductErrorResponse(w, r, prodStatus, err)
+		return
+	}
+	// Receipts are refused once a product is discontinued, sales still go through
+	if req.Quantity > 0 && !item.acceptsStock() {
+		writeStockRefused(w, item)
 		return
 	}
 
 	var inventory models.Inventory
 	err = h.DB.WithContext(ctx).
+		Scopes(tenantScope(ctx), variantScope(req.VariantID)).
 		Where("product_id = ? AND warehouse_location = ?", productID, req.WarehouseLocation).
 		First(&inventory).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		http.Error(w, "inventory record not found for given product and warehouse", http.StatusNotFound)
+		problem.Write(w, http.StatusNotFound, "inventory_not_found", "inventory record not found for given product and warehouse")
 		return
 	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("database error: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to fetch inventory", err)
 		return
 	}
 
 	inventory.Quantity += req.Quantity
 	if err := h.DB.WithContext(ctx).Save(&inventory).Error; err != nil {
-		http.Error(w, fmt.Sprintf("failed to update stock: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to update stock", err)
 		return
 	}
 
@@ -215,21 +384,22 @@ func (h *InventoryHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]any{
 		"message":   "stock updated successfully",
 		"product":   inventory.ProductID,
+		"variant":   inventory.VariantID,
 		"warehouse": inventory.WarehouseLocation,
 		"quantity":  inventory.Quantity,
 	})
 }
 
 func (h *InventoryHandler) LowStock(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	var lowStockItems []models.Inventory
 
 	if err := h.DB.WithContext(ctx).
+		Scopes(tenantScope(ctx)).
 		Where("quantity < ?", 10).
 		Find(&lowStockItems).Error; err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to fetch low stock items", err)
 		return
 	}
 
@@ -241,7 +411,7 @@ func (h *InventoryHandler) LowStock(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+		writeInternalError(w, r, "Failed to encode JSON", err)
 	}
 }
 
@@ -250,10 +420,7 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 
 	var req CheckAvailabilityRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "invalid request body",
-		})
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "invalid request body")
 		return
 	}
 
@@ -266,17 +433,33 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 	var unavailableService atomic.Bool
 
 	for i, item := range req.Items {
-		go func(i int, item struct {
-			ProductID string `json:"product_id"`
-			Quantity  int    `json:"quantity"`
-		}) {
+		go func(i int, item CheckAvailabilityItem) {
 			defer wg.Done()
 
-			productCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+			productCtx, cancel := product_clients.WithCallTimeout(ctx, h.ItemTimeout)
 			defer cancel()
 
-			// Check if product exists via Products Service
-			_, prodStatus, err := h.ProductsClient.GetProduct(productCtx, item.ProductID)
+			// One span per item shows the fan-out in the trace
+			productCtx, span := tracer.Start(productCtx, "CheckAvailability.item", trace.WithAttributes(
+				attribute.String("product.id", item.ProductID),
+				attribute.String("variant.id", item.VariantID),
+				attribute.Int("quantity.requested", item.Quantity),
+			))
+			defer span.End()
+
+			// Check if product (or variant) exists via Products Service
+			resolved, prodStatus, err := h.resolveItem(productCtx, item.ProductID, item.VariantID)
+			productID := resolved.ProductID
+			if errors.Is(err, errVariantMismatch) {
+				results[i] = ItemAvailability{
+					ProductID:      item.ProductID,
+					VariantID:      item.VariantID,
+					Requested:      item.Quantity,
+					AvailableStock: 0,
+					Status:         "variant_mismatch",
+				}
+				return
+			}
 			if err != nil {
 				errMsg := ""
 				switch prodStatus {
@@ -286,8 +469,12 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 					return
 				case 4:
 					errMsg = "product_not_found"
+					if item.VariantID != "" {
+						errMsg = "variant_not_found"
+					}
 					results[i] = ItemAvailability{
 						ProductID:      item.ProductID,
+						VariantID:      item.VariantID,
 						Requested:      item.Quantity,
 						AvailableStock: 0,
 						StatusCode:     prodStatus,
@@ -297,24 +484,35 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 				}
 			}
 
+			if productID == "" {
+				productID = item.ProductID
+			}
+
 			// Check inventory stock
 			var inventories []struct {
 				WarehouseLocation string
 				Quantity          int
 			}
 
-			err = h.DB.WithContext(productCtx).
+			// Without a variant every SKU of the product counts
+			query := h.DB.WithContext(productCtx).
 				Table("inventories").
 				Select("warehouse_location, quantity").
-				Where("product_id = ?", item.ProductID).
-				Scan(&inventories).Error
+				Scopes(tenantScope(productCtx)).
+				Where("product_id = ?", productID)
+			if item.VariantID != "" {
+				query = query.Where("variant_id = ?", item.VariantID)
+			}
+			err = query.Scan(&inventories).Error
 
 			if err != nil {
+				slog.ErrorContext(productCtx, "Failed to check stock", "product_id", productID, "error", err)
 				results[i] = ItemAvailability{
 					ProductID:      item.ProductID,
+					VariantID:      item.VariantID,
 					Requested:      item.Quantity,
 					AvailableStock: 0,
-					Status:         fmt.Sprintf("error_checking_stock: %e", err),
+					Status:         "error_checking_stock",
 				}
 				return
 			}
@@ -336,7 +534,8 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 			}
 
 			results[i] = ItemAvailability{
-				ProductID:      item.ProductID,
+				ProductID:      productID,
+				VariantID:      item.VariantID,
 				Requested:      item.Quantity,
 				AvailableStock: totalStock,
 				Status:         status,
@@ -350,11 +549,9 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 	// Checking if the products service is unavailable
 	// Return global error
 	if unavailableService.Load() {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]any{
-			"available": false,
-			"error":     "products_service_not_available",
-		})
+		problem.New(http.StatusServiceUnavailable, "products_service_unavailable", "The products service is unavailable").
+			With("available", false).
+			Write(w)
 		return
 	}
 
@@ -375,53 +572,21 @@ func (h *InventoryHandler) CheckAvailability(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (h *InventoryHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	dbStatus := "ok"
-
-	if err := h.DB.WithContext(ctx).Exec("SELECT 1").Error; err != nil {
-		dbStatus = "error"
-	}
-
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":   "ok",
-		"database": dbStatus,
-	})
-}
-
-func WriteProductErrorResponse(w http.ResponseWriter, prodStatus int, err error) {
-	w.Header().Set("Content-Type", "application/json")
-
-	var statusCode int
-	var msg string
+// WriteProductErrorResponse writes the error of a products service request.
+// The error itself is logged, as it names hosts and upstream responses.
+func WriteProductErrorResponse(w http.ResponseWriter, r *http.Request, prodStatus int, err error) {
+	slog.WarnContext(r.Context(), "products service request failed", "status", prodStatus, "error", err)
 
 	switch prodStatus {
-	case 1, 6: // request creation failed / unknown error
-		statusCode = http.StatusInternalServerError
-		msg = err.Error()
 	case 2: // timeouts
-		statusCode = http.StatusGatewayTimeout
-		msg = err.Error()
+		problem.Write(w, http.StatusGatewayTimeout, "products_service_timeout", "The products service did not respond in time")
 	case 5: // unavailable
-		statusCode = http.StatusServiceUnavailable
-		msg = err.Error()
+		problem.Write(w, http.StatusServiceUnavailable, "products_service_unavailable", "The products service is unavailable")
 	case 3: // request canceled
-		statusCode = http.StatusRequestTimeout
-		msg = err.Error()
+		problem.Write(w, http.StatusRequestTimeout, "request_canceled", "The request was canceled")
 	case 4: // product/s was not found
-		statusCode = http.StatusNotFound
-		msg = err.Error()
-	default:
-		statusCode = http.StatusInternalServerError
-		msg = err.Error()
+		problem.Write(w, http.StatusNotFound, "product_not_found", "Product or variant not found")
+	default: // request creation failed / unknown error
+		problem.Write(w, http.StatusBadGateway, "products_service_error", "The products service request failed")
 	}
-
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error": msg,
-	})
 }
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	// HTTPRequests counts requests by method, route template and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes request latency by method, route template and
+	// status code
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// UpstreamDuration observes products service calls by endpoint and
+	// outcome, ok or an error class
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "inventory_products_client_request_duration_seconds",
+		Help:    "Products service call latency by endpoint and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "outcome"})
+
+	// UpstreamErrors counts failed products service calls by endpoint and
+	// error class
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "inventory_products_client_errors_total",
+		Help: "Failed products service calls by endpoint and error class.",
+	}, []string{"endpoint", "class"})
+
+	// UpstreamCircuitOpen is 1 while the circuit to the products service is
+	// open
+	UpstreamCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "inventory_products_client_circuit_open",
+		Help: "Whether calls to the products service fail fast (1) or not (0).",
+	})
+)
+
+// RegisterDB exposes the connection pool stats of db as go_sql_* metrics
+// labeled with name.
+func RegisterDB(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return prometheus.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
@@ -0,0 +1,286 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is an IdempotencyStore in memory.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	err     error
+}
+
+func (s *memoryStore) Reserve(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return IdempotencyRecord{}, false, s.err
+	}
+	if existing, ok := s.records[key]; ok {
+		return existing, false, nil
+	}
+	s.records[key] = rec
+	return rec, true, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = rec
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fingerprint := func(method, target, body string) string {
+		return requestFingerprint(httptest.NewRequest(method, target, nil), []byte(body))
+	}
+	base := fingerprint(http.MethodPost, "/api/inventory", `{"name":"Mug"}`)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		same   bool
+	}{
+		{"same request", http.MethodPost, "/api/inventory", `{"name":"Mug"}`, true},
+		{"other body", http.MethodPost, "/api/inventory", `{"name":"Cup"}`, false},
+		{"other method", http.MethodPut, "/api/inventory", `{"name":"Mug"}`, false},
+		{"other path", http.MethodPost, "/api/inventory/reserve", `{"name":"Mug"}`, false},
+		{"other query", http.MethodPost, "/api/inventory?dry_run=true", `{"name":"Mug"}`, false},
+	}
+
+	for _, tt := range tests {
+		if got := fingerprint(tt.method, tt.target, tt.body); (got == base) != tt.same {
+			t.Errorf("%s: fingerprint equal = %v, want %v", tt.name, got == base, tt.same)
+		}
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	type step struct {
+		key          string
+		path         string
+		body         string
+		wantStatus   int
+		wantReplayed bool
+	}
+
+	tests := []struct {
+		name string
+		// status answers the nth call of the handler, counting from 1
+		status    func(call int) int
+		seed      map[string]IdempotencyRecord
+		storeErr  error
+		steps     []step
+		wantCalls int
+	}{
+		{
+			name: "without a key",
+			steps: []step{
+				{body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+				{body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "retry is replayed",
+			steps: []step{
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated, wantReplayed: true},
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "client errors are replayed",
+			status: func(int) int {
+				return http.StatusBadRequest
+			},
+			steps: []step{
+				{key: "k1", body: `{}`, wantStatus: http.StatusBadRequest},
+				{key: "k1", body: `{}`, wantStatus: http.StatusBadRequest, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "key reused with another body",
+			steps: []step{
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+				{key: "k1", body: `{"name":"Cup"}`, wantStatus: http.StatusUnprocessableEntity},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "keys are scoped to the path",
+			steps: []step{
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+				{key: "k1", path: "/api/warehouses", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "server errors can be retried",
+			status: func(call int) int {
+				if call == 1 {
+					return http.StatusInternalServerError
+				}
+				return http.StatusCreated
+			},
+			steps: []step{
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusInternalServerError},
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated},
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusCreated, wantReplayed: true},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "in flight",
+			seed: map[string]IdempotencyRecord{
+				"default POST /api/inventory k1": {Fingerprint: requestFingerprint(httptest.NewRequest(http.MethodPost, "/api/inventory", nil), []byte(`{"name":"Mug"}`))},
+			},
+			steps: []step{
+				{key: "k1", body: `{"name":"Mug"}`, wantStatus: http.StatusConflict},
+			},
+			wantCalls: 0,
+		},
+		{
+			name: "key too long",
+			steps: []step{
+				{key: strings.Repeat("k", maxIdempotencyKey+1), body: `{}`, wantStatus: http.StatusBadRequest},
+			},
+			wantCalls: 0,
+		},
+		{
+			name:     "store unavailable",
+			storeErr: errors.New("connection refused"),
+			steps: []step{
+				{key: "k1", body: `{}`, wantStatus: http.StatusServiceUnavailable},
+			},
+			wantCalls: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{records: map[string]IdempotencyRecord{}, err: tt.storeErr}
+			for key, rec := range tt.seed {
+				store.records[key] = rec
+			}
+
+			calls := 0
+			handler := RequestID(Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				status := http.StatusCreated
+				if tt.status != nil {
+					status = tt.status(calls)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Location", "/api/inventory/1")
+				w.WriteHeader(status)
+				w.Write([]byte(`{"id":"1"}`))
+			})))
+
+			for i, s := range tt.steps {
+				path := s.path
+				if path == "" {
+					path = "/api/inventory"
+				}
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(s.body))
+				if s.key != "" {
+					req.Header.Set(idempotencyHeader, s.key)
+				}
+				requestID := "request-" + string(rune('a'+i))
+				req.Header.Set(RequestIDHeader, requestID)
+				rec := httptest.NewRecorder()
+
+				handler.ServeHTTP(rec, req)
+
+				if rec.Code != s.wantStatus {
+					t.Fatalf("step %d: status = %d, want %d: %s", i, rec.Code, s.wantStatus, rec.Body)
+				}
+				if replayed := rec.Header().Get("Idempotent-Replayed") == "true"; replayed != s.wantReplayed {
+					t.Errorf("step %d: replayed = %v, want %v", i, replayed, s.wantReplayed)
+				}
+				if got := rec.Header().Get(RequestIDHeader); got != requestID {
+					t.Errorf("step %d: %s = %q, want the current %q", i, RequestIDHeader, got, requestID)
+				}
+				if s.wantReplayed {
+					if got := rec.Header().Get("Location"); got != "/api/inventory/1" {
+						t.Errorf("step %d: Location = %q, want it replayed", i, got)
+					}
+					if got := rec.Body.String(); got != `{"id":"1"}` {
+						t.Errorf("step %d: body = %q, want it replayed", i, got)
+					}
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyReleasesOnPanic(t *testing.T) {
+	store := &memoryStore{records: map[string]IdempotencyRecord{}}
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/inventory", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyHeader, "k1")
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("handler did not panic")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	if len(store.records) != 0 {
+		t.Errorf("records = %v, want the key released", store.records)
+	}
+}
+
+func TestStoredHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Location", "/api/inventory/1")
+	header.Set(RequestIDHeader, "abc")
+	header.Set("Date", "Mon, 02 Jan 2026 15:04:05 GMT")
+	header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	stored := storedHeader(header)
+
+	for _, name := range []string{"Content-Type", "Location"} {
+		if stored.Get(name) == "" {
+			t.Errorf("storedHeader() dropped %s", name)
+		}
+	}
+	for _, name := range perRequestHeaders {
+		if stored.Get(name) != "" {
+			t.Errorf("storedHeader() kept %s", name)
+		}
+	}
+	if header.Get(RequestIDHeader) != "abc" {
+		t.Error("storedHeader() modified its argument")
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		// timeout bounds the route, none if zero
+		timeout  time.Duration
+		work     time.Duration
+		wantBody string
+		wantErr  error
+	}{
+		{name: "unbounded route within the write timeout", work: 0, wantBody: "done"},
+		{name: "unbounded route past the write timeout", work: 300 * time.Millisecond, wantBody: ""},
+		{name: "bounded route past the write timeout", timeout: time.Second, work: 300 * time.Millisecond, wantBody: "done"},
+		{name: "canceled after the timeout", timeout: 50 * time.Millisecond, work: 300 * time.Millisecond, wantBody: "done", wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxErr error
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.work):
+				case <-r.Context().Done():
+				}
+				ctxErr = r.Context().Err()
+				io.WriteString(w, "done")
+			})
+			if tt.timeout > 0 {
+				handler = Timeout(tt.timeout)(handler)
+			}
+
+			srv := httptest.NewUnstartedServer(handler)
+			srv.Config.WriteTimeout = 100 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			var body []byte
+			resp, err := http.Get(srv.URL)
+			if err == nil {
+				body, _ = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q (error %v), want %q", body, err, tt.wantBody)
+			}
+			if tt.wantBody != "" && !errors.Is(ctxErr, tt.wantErr) {
+				t.Errorf("context error = %v, want %v", ctxErr, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey stores the response of a request made with an
+// Idempotency-Key. A zero Status means the request is still in flight.
+type IdempotencyKey struct {
+	Key         string    `gorm:"primaryKey"`
+	Fingerprint string    `gorm:"not null"`
+	Status      int       `gorm:"not null;default:0"`
+	Header      string    `gorm:"type:jsonb;not null;default:'{}'"`
+	Body        []byte    `gorm:"type:bytea"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
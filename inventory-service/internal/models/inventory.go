@@ -6,7 +6,9 @@ import (
 
 type Inventory struct {
 	ID                string    `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID          string    `json:"tenant_id" gorm:"not null;default:'default';index"`
 	ProductID         string    `json:"product_id" gorm:"not null;index"`
+	VariantID         *string   `json:"variant_id,omitempty" gorm:"index"`
 	Quantity          int       `json:"quantity" gorm:"not null"`
 	WarehouseLocation string    `json:"warehouse_location" gorm:"not null;index"`
 	LastUpdated       time.Time `json:"last_updated" gorm:"autoUpdateTime"`
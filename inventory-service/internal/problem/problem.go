@@ -0,0 +1,67 @@
+// Package problem writes error responses as RFC 7807 problem details, the
+// one error format of the service.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ContentType is the media type of a problem details body
+const ContentType = "application/problem+json"
+
+// requestIDHeader is set on the response by middleware.RequestID, which
+// cannot be imported from here
+const requestIDHeader = "X-Request-ID"
+
+// Problem is a problem details body. Besides the standard members it has a
+// stable, machine-readable code and the request ID, and may carry extra
+// members such as the current version of a conflicting resource.
+type Problem map[string]any
+
+// New returns the problem of a response with status. code names the error
+// and does not change between releases, detail explains it to a person.
+func New(status int, code, detail string) Problem {
+	return Problem{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"code":   code,
+		"detail": detail,
+	}
+}
+
+// With adds an extra member to p.
+func (p Problem) With(key string, value any) Problem {
+	p[key] = value
+	return p
+}
+
+// Write sends p, tagged with the request ID.
+func (p Problem) Write(w http.ResponseWriter) {
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		p["request_id"] = id
+	}
+
+	status, _ := p["status"].(int)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Write sends a problem with status, code and detail.
+func Write(w http.ResponseWriter, status int, code, detail string) {
+	New(status, code, detail).Write(w)
+}
+
+// Code is the generic code of status, for errors that need no specific one.
+func Code(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusInternalServerError:
+		return "internal_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
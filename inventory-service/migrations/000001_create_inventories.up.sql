@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS inventories (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    product_id text NOT NULL,
+    quantity bigint NOT NULL,
+    warehouse_location text NOT NULL,
+    last_updated timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventories_product_id ON inventories (product_id);
+CREATE INDEX IF NOT EXISTS idx_inventories_warehouse_location ON inventories (warehouse_location);
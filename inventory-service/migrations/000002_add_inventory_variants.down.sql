@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inventories_variant_id;
+
+ALTER TABLE inventories DROP COLUMN IF EXISTS variant_id;
@@ -0,0 +1,3 @@
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS variant_id text;
+
+CREATE INDEX IF NOT EXISTS idx_inventories_variant_id ON inventories (variant_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key text PRIMARY KEY,
+    fingerprint text NOT NULL,
+    status bigint NOT NULL DEFAULT 0,
+    header jsonb NOT NULL DEFAULT '{}',
+    body bytea,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inventories_tenant_id;
+
+ALTER TABLE inventories DROP COLUMN IF EXISTS tenant_id;
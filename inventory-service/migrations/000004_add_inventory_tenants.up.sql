@@ -0,0 +1,4 @@
+-- Existing stock belongs to the default tenant
+ALTER TABLE inventories ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_inventories_tenant_id ON inventories (tenant_id);
@@ -1,71 +1,296 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/joho/godotenv"
-
+	"github.com/MosaabBleik/products-service/internal/auth"
 	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/config"
+	"github.com/MosaabBleik/products-service/internal/currency"
 	"github.com/MosaabBleik/products-service/internal/database"
 	"github.com/MosaabBleik/products-service/internal/handlers"
+	"github.com/MosaabBleik/products-service/internal/jobs"
+	"github.com/MosaabBleik/products-service/internal/logging"
+	"github.com/MosaabBleik/products-service/internal/metrics"
 	"github.com/MosaabBleik/products-service/internal/middleware"
 	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/scheduler"
+	"github.com/MosaabBleik/products-service/internal/telemetry"
+	"github.com/MosaabBleik/products-service/internal/tenant"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// Load env vars
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+	// Schema migrations, e.g. products-service migrate status, need only the database
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		cfg, err := config.LoadDatabase()
+		if err != nil {
+			log.Fatalf("Invalid configuration:\n%v", err)
+		}
+		if err := logging.Setup("products-service", cfg.Log.Level); err != nil {
+			log.Fatalf("Logging configuration failed: %v", err)
+		}
+		if err := runMigrate(cfg.Database.URL, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
 
-	// Connect to database
-	db := database.Connect()
+	// Defaults, CONFIG_FILE, .env and env vars, validated together
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
-	// Auto migration
-	err := db.AutoMigrate(&models.Product{})
+	// JSON logs, at the level set by LOG_LEVEL
+	if err := logging.Setup("products-service", cfg.Log.Level); err != nil {
+		log.Fatalf("Logging configuration failed: %v", err)
+	}
+
+	// Traces are exported when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := telemetry.Init(context.Background(), "products-service")
 	if err != nil {
-		log.Fatalf("Migration failed: %v", err)
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	// Pending migrations are applied at startup unless disabled
+	if cfg.Database.MigrateOnStart {
+		if err := database.MigrateUp(cfg.Database.URL); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	}
+
+	// Connect to database
+	db := database.Connect(cfg.Database.URL)
+	if err := metrics.RegisterDB(db, "products"); err != nil {
+		log.Fatalf("Failed to register database metrics: %v", err)
+	}
+
+	// Import files do not survive a restart
+	if err := database.FailInterruptedImports(db); err != nil {
+		log.Fatalf("Failed to reset interrupted imports: %v", err)
 	}
 
 	// Cache Redis Client
-	redisClient, err := cache.InitRedis()
+	redisClient, err := cache.InitRedis(cfg.Redis.Addr)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
+	// Exchange rates for currency fallback conversion
+	rates, err := currency.ParseRates(cfg.Currency.ExchangeRates)
+	if err != nil {
+		log.Fatalf("Invalid exchange rates: %v", err)
+	}
+
 	productHandler := handlers.ProductHandler{
+		DB:              db,
+		RedisClient:     redisClient,
+		Rates:           rates,
+		DefaultCurrency: cfg.Currency.Default,
+		CacheControl: handlers.CacheControl{
+			Product: cfg.CacheControl.Product,
+			List:    cfg.CacheControl.List,
+			Search:  cfg.CacheControl.Search,
+		},
+		BulkWorkers: cfg.Jobs.BulkUpdateWorkers,
+	}
+
+	categoryHandler := handlers.CategoryHandler{
+		DB:          db,
+		RedisClient: redisClient,
+	}
+
+	// Background work runs until shutdown
+	workCtx, stopWork := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	// Scheduled price changes
+	priceScheduler := &scheduler.PriceScheduler{
 		DB:          db,
 		RedisClient: redisClient,
+		Interval:    cfg.Jobs.PriceSchedulerInterval,
+	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		priceScheduler.Run(workCtx)
+	}()
+
+	// Background jobs
+	jobRunner := &jobs.Runner{
+		DB:           db,
+		Workers:      cfg.Jobs.Workers,
+		PollInterval: cfg.Jobs.PollInterval,
+	}
+	jobRunner.Register(models.JobTypeBulkUpdate, productHandler.RunBulkUpdateJob)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		jobRunner.Run(workCtx)
+	}()
+
+	jobHandler := handlers.JobHandler{
+		DB: db,
+	}
+
+	configHandler := handlers.ConfigHandler{
+		Config: cfg,
+	}
+
+	// Retried POST requests with an Idempotency-Key replay the first response
+	idempotent := middleware.Idempotency(&cache.IdempotencyStore{Client: redisClient}, cfg.IdempotencyTTL)
+
+	// Reads need any catalog role, changes need catalog-admin
+	authenticator, err := auth.FromConfig(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Auth configuration failed: %v", err)
+	}
+	read := authenticator.Require(auth.RoleReader, auth.RoleCatalogAdmin)
+	write := authenticator.Require(auth.RoleCatalogAdmin)
+
+	// API routes are bounded in time and body size, and those taking a body
+	// take JSON only. Reads get a shorter bound, bulk updates a longer one.
+	// Imports and exports set their own limits.
+	bounded := func(timeout time.Duration) func(http.Handler) http.Handler {
+		return middleware.Chain(middleware.Timeout(timeout), middleware.MaxBytes(int64(cfg.Handlers.MaxBodyBytes)))
 	}
+	readAPI := bounded(cfg.Handlers.ReadTimeout)
+	api := bounded(cfg.Handlers.Timeout)
+	jsonAPI := middleware.Chain(api, middleware.RequireJSON)
+	bulkAPI := middleware.Chain(bounded(cfg.Handlers.BulkTimeout), middleware.RequireJSON)
 
 	// Router
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowed)
 
 	// Advanced search
-	r.HandleFunc("/api/products/search", productHandler.Search).Methods("GET")
+	r.Handle("/api/products/search", read(readAPI(http.HandlerFunc(productHandler.Search)))).Methods("GET")
+
+	// Catalog export
+	r.Handle("/api/products/export", read(http.HandlerFunc(productHandler.ExportProducts))).Methods("GET")
 
 	// CRUD handlers
-	r.HandleFunc("/api/products", productHandler.ListProducts).Methods("GET")
-	r.HandleFunc("/api/products", productHandler.CreateProduct).Methods("POST")
-	r.HandleFunc("/api/products/{id}", productHandler.GetProduct).Methods("GET")
-	r.HandleFunc("/api/products/{id}", productHandler.UpdateProduct).Methods("PUT")
-	r.HandleFunc("/api/products/{id}", productHandler.DeleteProduct).Methods("DELETE")
+	r.Handle("/api/products", read(readAPI(http.HandlerFunc(productHandler.ListProducts)))).Methods("GET")
+	r.Handle("/api/products", write(jsonAPI(idempotent(http.HandlerFunc(productHandler.CreateProduct))))).Methods("POST")
+	r.Handle("/api/products/{id}", read(readAPI(http.HandlerFunc(productHandler.GetProduct)))).Methods("GET")
+	r.Handle("/api/products/{id}", write(jsonAPI(http.HandlerFunc(productHandler.UpdateProduct)))).Methods("PUT")
+	r.Handle("/api/products/{id}", write(jsonAPI(http.HandlerFunc(productHandler.PatchProduct)))).Methods("PATCH")
+	r.Handle("/api/products/{id}", write(api(http.HandlerFunc(productHandler.DeleteProduct)))).Methods("DELETE")
+
+	// Publication status
+	r.Handle("/api/products/{id}/status", write(jsonAPI(http.HandlerFunc(productHandler.SetStatus)))).Methods("POST")
+
+	// Audit trail
+	r.Handle("/api/products/{id}/audit", read(readAPI(http.HandlerFunc(productHandler.ProductAudit)))).Methods("GET")
+
+	// Price history and scheduled price changes
+	r.Handle("/api/products/{id}/price-history", read(readAPI(http.HandlerFunc(productHandler.PriceHistory)))).Methods("GET")
+	r.Handle("/api/products/{id}/scheduled-prices", read(readAPI(http.HandlerFunc(productHandler.ListScheduledPrices)))).Methods("GET")
+	r.Handle("/api/products/{id}/scheduled-prices", write(jsonAPI(idempotent(http.HandlerFunc(productHandler.SchedulePrice))))).Methods("POST")
+	r.Handle("/api/products/{id}/scheduled-prices/{schedule_id}", write(api(http.HandlerFunc(productHandler.CancelScheduledPrice)))).Methods("DELETE")
+
+	// Variants
+	r.Handle("/api/products/{id}/variants", read(readAPI(http.HandlerFunc(productHandler.ListVariants)))).Methods("GET")
+	r.Handle("/api/products/{id}/variants", write(jsonAPI(idempotent(http.HandlerFunc(productHandler.CreateVariant))))).Methods("POST")
+	r.Handle("/api/products/{id}/variants/{variant_id}", write(jsonAPI(http.HandlerFunc(productHandler.UpdateVariant)))).Methods("PUT")
+	r.Handle("/api/products/{id}/variants/{variant_id}", write(api(http.HandlerFunc(productHandler.DeleteVariant)))).Methods("DELETE")
+	r.Handle("/api/variants/{variant_id}", read(readAPI(http.HandlerFunc(productHandler.GetVariant)))).Methods("GET")
+
+	// Categories
+	r.Handle("/api/categories", read(readAPI(http.HandlerFunc(categoryHandler.ListCategories)))).Methods("GET")
+	r.Handle("/api/categories", write(jsonAPI(idempotent(http.HandlerFunc(categoryHandler.CreateCategory))))).Methods("POST")
+	r.Handle("/api/categories/{slug}", read(readAPI(http.HandlerFunc(categoryHandler.GetCategory)))).Methods("GET")
+	r.Handle("/api/categories/{slug}", write(jsonAPI(http.HandlerFunc(categoryHandler.UpdateCategory)))).Methods("PUT")
+	r.Handle("/api/categories/{slug}", write(api(http.HandlerFunc(categoryHandler.DeleteCategory)))).Methods("DELETE")
 
 	// Bulk update
-	r.HandleFunc("/api/products/bulk-update", productHandler.BulkUpdate).Methods("POST")
+	r.Handle("/api/products/bulk-update", write(bulkAPI(idempotent(http.HandlerFunc(productHandler.BulkUpdate))))).Methods("POST")
+
+	// Bulk import
+	r.Handle("/api/products/import", write(http.HandlerFunc(productHandler.ImportProducts))).Methods("POST")
+	r.Handle("/api/products/imports/{import_id}", read(readAPI(http.HandlerFunc(productHandler.GetImport)))).Methods("GET")
 
-	// Health check
-	r.HandleFunc("/api/health", productHandler.HealthCheck).Methods("GET")
+	// Jobs
+	r.Handle("/api/jobs", read(readAPI(http.HandlerFunc(jobHandler.ListJobs)))).Methods("GET")
+	r.Handle("/api/jobs/{job_id}", read(readAPI(http.HandlerFunc(jobHandler.GetJob)))).Methods("GET")
+	r.Handle("/api/jobs/{job_id}/cancel", write(api(http.HandlerFunc(jobHandler.CancelJob)))).Methods("POST")
 
-	loggedRouter := middleware.Logger(r)
+	// Effective configuration, secrets redacted
+	r.Handle("/api/admin/config", write(readAPI(http.HandlerFunc(configHandler.GetConfig)))).Methods("GET")
 
-	port := os.Getenv("PORT")
-	portStr := fmt.Sprintf(":%s", port)
+	// Liveness and readiness probes, /api/health is kept for existing checks
+	r.HandleFunc("/livez", productHandler.Live).Methods("GET")
+	r.HandleFunc("/readyz", productHandler.Ready).Methods("GET")
+	r.HandleFunc("/api/health", productHandler.Ready).Methods("GET")
 
-	fmt.Println("Server started at :", port)
-	log.Fatal(http.ListenAndServe(portStr, loggedRouter))
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// Every request belongs to a tenant, resolved once the caller is known.
+	// Panics are recovered inside the logger so they are logged as 500s.
+	loggedRouter := middleware.Chain(
+		middleware.Tracing(r, "products-service"),
+		middleware.RequestID,
+		middleware.Metrics(r),
+		middleware.Logger,
+		middleware.Recover,
+		authenticator.Authenticate,
+		tenant.Resolve,
+	)(r)
+
+	server := newServer(cfg.Server, loggedRouter)
+
+	// SIGINT or SIGTERM starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		slog.Info("Server started", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down")
+
+	// In-flight requests are drained first, then running jobs are requeued
+	// and imports given the time left to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain requests", "error", err)
+	}
+	stopWork()
+	if err := wait(shutdownCtx, &workers); err != nil {
+		slog.Error("Background workers did not stop", "error", err)
+	}
+	if err := productHandler.WaitImports(shutdownCtx); err != nil {
+		slog.Error("Imports did not finish, they are marked failed at the next start", "error", err)
+	}
+
+	if err := redisClient.Close(); err != nil {
+		slog.Error("Failed to close Redis", "error", err)
+	}
+	if err := database.Close(db); err != nil {
+		slog.Error("Failed to close DB", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionPatch          = "patch"
+	ActionDelete         = "delete"
+	ActionBulk           = "bulk_update"
+	ActionImport         = "import"
+	ActionSchedule       = "schedule"
+	ActionStatus         = "status"
+	ActionCategoryRename = "category_rename"
+)
+
+// Change identifies who made a product change, for which tenant and through
+// which request.
+type Change struct {
+	Tenant    string
+	Action    string
+	Actor     string
+	RequestID string
+}
+
+// Snapshot returns the audited fields of a product. Empty price lists and
+// attributes are left out, so creating a product does not report them.
+func Snapshot(p models.Product) map[string]any {
+	fields := map[string]any{
+		"name":        p.Name,
+		"description": p.Description,
+		"price":       p.Price,
+		"currency":    p.Currency,
+		"category":    p.Category,
+		"status":      p.Status,
+	}
+	if p.ExternalSKU != nil {
+		fields["external_sku"] = *p.ExternalSKU
+	}
+	if len(p.Prices) > 0 {
+		prices := make(map[string]float64, len(p.Prices))
+		for _, pp := range p.Prices {
+			prices[pp.Currency] = pp.Amount
+		}
+		fields["prices"] = prices
+	}
+	if len(p.Attributes) > 0 {
+		fields["attributes"] = p.Attributes
+	}
+	return fields
+}
+
+// Diff returns the fields that differ between two snapshots. A nil snapshot
+// stands for a product that does not exist.
+func Diff(before, after map[string]any) models.AuditChanges {
+	changes := models.AuditChanges{}
+	for field, oldValue := range before {
+		if newValue := after[field]; !sameValue(oldValue, newValue) {
+			changes[field] = models.FieldChange{Before: oldValue, After: newValue}
+		}
+	}
+	for field, newValue := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = models.FieldChange{After: newValue}
+		}
+	}
+	return changes
+}
+
+// sameValue compares values by their JSON form, which is what gets stored.
+func sameValue(a, b any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
+
+// Record stores an audit entry for the changed fields of a product. Nothing
+// is stored when no field changed.
+func Record(tx *gorm.DB, productID string, change Change, before, after map[string]any) error {
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return tx.Create(&models.AuditEntry{
+		TenantID:  change.Tenant,
+		ProductID: productID,
+		Action:    change.Action,
+		Actor:     change.Actor,
+		RequestID: change.RequestID,
+		Changes:   changes,
+	}).Error
+}
+
+// RecordCategoryRename stores an audit entry for every product of the tenant
+// moved from category oldSlug to newSlug, in one statement.
+func RecordCategoryRename(tx *gorm.DB, oldSlug, newSlug string, change Change) error {
+	changes, err := json.Marshal(models.AuditChanges{
+		"category": {Before: oldSlug, After: newSlug},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(`INSERT INTO product_audit (tenant_id, product_id, action, actor, request_id, changes, created_at)
+		SELECT tenant_id, id, ?, ?, ?, ?, NOW() FROM products WHERE tenant_id = ? AND category = ?`,
+		change.Action, change.Actor, change.RequestID, string(changes), change.Tenant, oldSlug).Error
+}
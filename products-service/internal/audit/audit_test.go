@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]any
+		want          models.AuditChanges
+	}{
+		{
+			name: "both nil",
+			want: models.AuditChanges{},
+		},
+		{
+			name:   "create",
+			before: nil,
+			after:  map[string]any{"name": "Mug", "price": 10.0},
+			want: models.AuditChanges{
+				"name":  {After: "Mug"},
+				"price": {After: 10.0},
+			},
+		},
+		{
+			name:   "delete",
+			before: map[string]any{"name": "Mug"},
+			after:  nil,
+			want: models.AuditChanges{
+				"name": {Before: "Mug"},
+			},
+		},
+		{
+			name:   "unchanged",
+			before: map[string]any{"name": "Mug", "price": 10.0},
+			after:  map[string]any{"name": "Mug", "price": 10.0},
+			want:   models.AuditChanges{},
+		},
+		{
+			name:   "changed field only",
+			before: map[string]any{"name": "Mug", "price": 10.0},
+			after:  map[string]any{"name": "Mug", "price": 12.5},
+			want: models.AuditChanges{
+				"price": {Before: 10.0, After: 12.5},
+			},
+		},
+		{
+			name:   "added and removed fields",
+			before: map[string]any{"name": "Mug", "external_sku": "M-1"},
+			after:  map[string]any{"name": "Mug", "attributes": models.JSONMap{"color": "red"}},
+			want: models.AuditChanges{
+				"external_sku": {Before: "M-1"},
+				"attributes":   {After: models.JSONMap{"color": "red"}},
+			},
+		},
+		{
+			name:   "values compared by JSON form",
+			before: map[string]any{"price": 10, "prices": map[string]float64{"USD": 2}},
+			after:  map[string]any{"price": 10.0, "prices": map[string]float64{"USD": 2.0}},
+			want:   models.AuditChanges{},
+		},
+		{
+			name:   "nested change",
+			before: map[string]any{"prices": map[string]float64{"USD": 2}},
+			after:  map[string]any{"prices": map[string]float64{"USD": 3}},
+			want: models.AuditChanges{
+				"prices": {Before: map[string]float64{"USD": 2}, After: map[string]float64{"USD": 3}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	sku := "M-1"
+	tests := []struct {
+		name    string
+		product models.Product
+		want    map[string]any
+	}{
+		{
+			name:    "empty lists left out",
+			product: models.Product{Name: "Mug", Price: 10, Currency: "SAR", Category: "kitchen", Status: models.ProductStatusDraft},
+			want: map[string]any{
+				"name": "Mug", "description": "", "price": 10.0, "currency": "SAR",
+				"category": "kitchen", "status": models.ProductStatusDraft,
+			},
+		},
+		{
+			name: "all fields",
+			product: models.Product{
+				Name: "Mug", Price: 10, Currency: "SAR", Category: "kitchen", Status: models.ProductStatusActive,
+				ExternalSKU: &sku,
+				Prices:      []models.ProductPrice{{Currency: "USD", Amount: 2.5}},
+				Attributes:  models.JSONMap{"color": "red"},
+			},
+			want: map[string]any{
+				"name": "Mug", "description": "", "price": 10.0, "currency": "SAR",
+				"category": "kitchen", "status": models.ProductStatusActive,
+				"external_sku": "M-1",
+				"prices":       map[string]float64{"USD": 2.5},
+				"attributes":   models.JSONMap{"color": "red"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snapshot(tt.product); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Snapshot() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyKeyPrefix = "products:idempotency:"
+
+// IdempotencyStore keeps idempotency records in Redis.
+type IdempotencyStore struct {
+	Client *redis.Client
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, key string, rec middleware.IdempotencyRecord, ttl time.Duration) (middleware.IdempotencyRecord, bool, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return middleware.IdempotencyRecord{}, false, err
+	}
+
+	reserved, err := s.Client.SetNX(ctx, idempotencyKeyPrefix+key, b, ttl).Result()
+	if err != nil || reserved {
+		return middleware.IdempotencyRecord{}, reserved, err
+	}
+
+	stored, err := s.Client.Get(ctx, idempotencyKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired in between, try again
+		return s.Reserve(ctx, key, rec, ttl)
+	}
+	if err != nil {
+		return middleware.IdempotencyRecord{}, false, err
+	}
+
+	var existing middleware.IdempotencyRecord
+	if err := json.Unmarshal(stored, &existing); err != nil {
+		return middleware.IdempotencyRecord{}, false, err
+	}
+	return existing, false, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, rec middleware.IdempotencyRecord, ttl time.Duration) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, idempotencyKeyPrefix+key, b, ttl).Err()
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.Client.Del(ctx, idempotencyKeyPrefix+key).Err()
+}
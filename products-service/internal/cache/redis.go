@@ -2,19 +2,23 @@ package cache
 
 import (
 	"context"
-	"os"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
-func InitRedis() (*redis.Client, error) {
-	redisURL := os.Getenv("REDIS_URL")
+func InitRedis(addr string) (*redis.Client, error) {
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     redisURL,
+		Addr:     addr,
 		Password: "",
 		DB:       0,
 	})
 
+	// Commands run with a request context join its trace
+	if err := redisotel.InstrumentTracing(redisClient); err != nil {
+		return nil, err
+	}
+
 	ctx := context.Background()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
 		return nil, err
@@ -22,3 +26,42 @@ func InitRedis() (*redis.Client, error) {
 
 	return redisClient, nil
 }
+
+// SearchKeyPrefix namespaces the cached search results of a tenant.
+func SearchKeyPrefix(tenant string) string {
+	return "products:" + tenant + ":search:"
+}
+
+// InvalidateSearch drops the cached search results of a tenant.
+func InvalidateSearch(ctx context.Context, redisClient *redis.Client, tenant string) error {
+	return unlinkMatching(ctx, redisClient, SearchKeyPrefix(tenant)+"*")
+}
+
+// InvalidateAllSearches drops the cached search results of every tenant,
+// after a change that is not limited to one tenant.
+func InvalidateAllSearches(ctx context.Context, redisClient *redis.Client) error {
+	return unlinkMatching(ctx, redisClient, SearchKeyPrefix("*")+"*")
+}
+
+func unlinkMatching(ctx context.Context, redisClient *redis.Client, pattern string) error {
+	iter := redisClient.Scan(ctx, 0, pattern, 500).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := redisClient.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		return redisClient.Unlink(ctx, keys...).Err()
+	}
+	return nil
+}
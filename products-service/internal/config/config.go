@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/currency"
+)
+
+// Config is the configuration of the products service. Every setting has a
+// YAML key and an environment variable, see Load.
+type Config struct {
+	Server         Server        `yaml:"server"`
+	Log            Log           `yaml:"log"`
+	Database       Database      `yaml:"database"`
+	Redis          Redis         `yaml:"redis"`
+	Auth           Auth          `yaml:"auth"`
+	Currency       Currency      `yaml:"currency"`
+	CacheControl   CacheControl  `yaml:"cache_control"`
+	Jobs           Jobs          `yaml:"jobs"`
+	Handlers       Handlers      `yaml:"handlers"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL"`
+}
+
+type Server struct {
+	Port              int           `yaml:"port" env:"PORT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type Log struct {
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+type Database struct {
+	URL            string `yaml:"url" env:"DATABASE_URL" secret:"url"`
+	MigrateOnStart bool   `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+}
+
+type Redis struct {
+	Addr string `yaml:"addr" env:"REDIS_URL"`
+}
+
+type Auth struct {
+	JWTSecret        string `yaml:"jwt_secret" env:"AUTH_JWT_SECRET" secret:"true"`
+	JWTPublicKeyFile string `yaml:"jwt_public_key_file" env:"AUTH_JWT_PUBLIC_KEY_FILE"`
+	JWKSURL          string `yaml:"jwks_url" env:"AUTH_JWKS_URL"`
+	JWTIssuer        string `yaml:"jwt_issuer" env:"AUTH_JWT_ISSUER"`
+	JWTAudience      string `yaml:"jwt_audience" env:"AUTH_JWT_AUDIENCE"`
+	RolesClaim       string `yaml:"roles_claim" env:"AUTH_ROLES_CLAIM"`
+	TenantClaim      string `yaml:"tenant_claim" env:"AUTH_TENANT_CLAIM"`
+	APIKeys          string `yaml:"api_keys" env:"AUTH_API_KEYS" secret:"true"`
+	Disabled         bool   `yaml:"disabled" env:"AUTH_DISABLED"`
+}
+
+type Currency struct {
+	Default       string `yaml:"default" env:"DEFAULT_CURRENCY"`
+	ExchangeRates string `yaml:"exchange_rates" env:"EXCHANGE_RATES"`
+}
+
+type CacheControl struct {
+	Product string `yaml:"product" env:"PRODUCT_CACHE_CONTROL"`
+	List    string `yaml:"list" env:"LIST_CACHE_CONTROL"`
+	Search  string `yaml:"search" env:"SEARCH_CACHE_CONTROL"`
+}
+
+type Jobs struct {
+	Workers                int           `yaml:"workers" env:"JOB_WORKERS"`
+	PollInterval           time.Duration `yaml:"poll_interval" env:"JOB_POLL_INTERVAL"`
+	BulkUpdateWorkers      int           `yaml:"bulk_update_workers" env:"BULK_UPDATE_WORKERS"`
+	PriceSchedulerInterval time.Duration `yaml:"price_scheduler_interval" env:"PRICE_SCHEDULER_INTERVAL"`
+}
+
+// Handlers bounds the API routes. Imports and exports, which can run for
+// long and take large files, are exempt.
+type Handlers struct {
+	// Timeout bounds the work of a request, queries included. Reads and
+	// bulk updates have their own bounds.
+	Timeout     time.Duration `yaml:"timeout" env:"HANDLER_TIMEOUT"`
+	ReadTimeout time.Duration `yaml:"read_timeout" env:"HANDLER_READ_TIMEOUT"`
+	BulkTimeout time.Duration `yaml:"bulk_timeout" env:"HANDLER_BULK_TIMEOUT"`
+	// MaxBodyBytes bounds the body of a request
+	MaxBodyBytes int `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: Server{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Log:      Log{Level: "info"},
+		Database: Database{MigrateOnStart: true},
+		Redis:    Redis{Addr: "localhost:6379"},
+		Auth:     Auth{RolesClaim: "roles", TenantClaim: "tenant_id"},
+		Currency: Currency{Default: "SAR"},
+		CacheControl: CacheControl{
+			Product: "no-cache",
+			List:    "no-cache",
+			Search:  "no-cache",
+		},
+		Jobs: Jobs{
+			Workers:                2,
+			PollInterval:           time.Second,
+			BulkUpdateWorkers:      10,
+			PriceSchedulerInterval: 30 * time.Second,
+		},
+		Handlers: Handlers{
+			Timeout:      30 * time.Second,
+			ReadTimeout:  10 * time.Second,
+			BulkTimeout:  5 * time.Minute,
+			MaxBodyBytes: 1 << 20,
+		},
+		IdempotencyTTL: 24 * time.Hour,
+	}
+}
+
+func (c *Config) validate() []error {
+	var errs check
+	errs.failIf(c.Server.Port < 1 || c.Server.Port > 65535, "PORT must be between 1 and 65535, got %d", c.Server.Port)
+	errs.failIf(c.Server.ReadHeaderTimeout <= 0, "HTTP_READ_HEADER_TIMEOUT must be positive")
+	errs.failIf(c.Server.ReadTimeout <= 0, "HTTP_READ_TIMEOUT must be positive")
+	errs.failIf(c.Server.WriteTimeout <= 0, "HTTP_WRITE_TIMEOUT must be positive")
+	errs.failIf(c.Server.IdleTimeout <= 0, "HTTP_IDLE_TIMEOUT must be positive")
+	errs.failIf(c.Server.ShutdownTimeout <= 0, "SHUTDOWN_TIMEOUT must be positive")
+
+	errs = append(errs, c.validateDatabase()...)
+
+	errs.failIf(c.Redis.Addr == "", "REDIS_URL is required")
+
+	c.Currency.Default = currency.Normalize(c.Currency.Default)
+	errs.failIf(!currency.Valid(c.Currency.Default), "DEFAULT_CURRENCY must be a 3-letter currency code, got %q", c.Currency.Default)
+	if _, err := currency.ParseRates(c.Currency.ExchangeRates); err != nil {
+		errs = append(errs, fmt.Errorf("EXCHANGE_RATES: %w", err))
+	}
+
+	errs.failIf(c.Jobs.Workers <= 0, "JOB_WORKERS must be positive")
+	errs.failIf(c.Jobs.PollInterval <= 0, "JOB_POLL_INTERVAL must be positive")
+	errs.failIf(c.Jobs.BulkUpdateWorkers <= 0, "BULK_UPDATE_WORKERS must be positive")
+	errs.failIf(c.Jobs.PriceSchedulerInterval <= 0, "PRICE_SCHEDULER_INTERVAL must be positive")
+	errs.failIf(c.Handlers.Timeout <= 0, "HANDLER_TIMEOUT must be positive")
+	errs.failIf(c.Handlers.ReadTimeout <= 0, "HANDLER_READ_TIMEOUT must be positive")
+	errs.failIf(c.Handlers.BulkTimeout <= 0, "HANDLER_BULK_TIMEOUT must be positive")
+	errs.failIf(c.Handlers.MaxBodyBytes <= 0, "MAX_BODY_BYTES must be positive")
+	errs.failIf(c.IdempotencyTTL <= 0, "IDEMPOTENCY_TTL must be positive")
+	return errs
+}
+
+// validateDatabase checks the settings the migrate subcommand uses.
+func (c *Config) validateDatabase() []error {
+	var errs check
+	var level slog.Level
+	errs.failIf(level.UnmarshalText([]byte(c.Log.Level)) != nil, "LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	errs.failIf(c.Database.URL == "", "DATABASE_URL is required")
+	return errs
+}
+
+// Redacted returns the effective configuration with secrets hidden.
+func (c *Config) Redacted() map[string]any {
+	return redact(reflect.ValueOf(c).Elem())
+}
@@ -0,0 +1,302 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads for the duration of the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+
+	var clear func(reflect.Type)
+	clear = func(typ reflect.Type) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			if field.Type.Kind() == reflect.Struct {
+				clear(field.Type)
+				continue
+			}
+			if name := field.Tag.Get("env"); name != "" {
+				t.Setenv(name, "")
+			}
+		}
+	}
+	clear(reflect.TypeOf(Config{}))
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		file  string
+		check func(t *testing.T, cfg *Config)
+		// wantErrs are parts of the error, all reported at once
+		wantErrs []string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{"DATABASE_URL": "postgres://localhost/products"},
+			check: func(t *testing.T, cfg *Config) {
+				want := defaults()
+				want.Database.URL = "postgres://localhost/products"
+				if !reflect.DeepEqual(cfg, want) {
+					t.Errorf("Load() = %+v, want %+v", cfg, want)
+				}
+			},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"DATABASE_URL":      "postgres://localhost/products",
+				"PORT":              "9090",
+				"HANDLER_TIMEOUT":   "45",
+				"JOB_POLL_INTERVAL": "250ms",
+				"MIGRATE_ON_START":  "false",
+				"DEFAULT_CURRENCY":  " usd ",
+				"LOG_LEVEL":         "debug",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != 9090 {
+					t.Errorf("Port = %d, want 9090", cfg.Server.Port)
+				}
+				if cfg.Handlers.Timeout != 45*time.Second {
+					t.Errorf("Handlers.Timeout = %v, want 45s", cfg.Handlers.Timeout)
+				}
+				if cfg.Jobs.PollInterval != 250*time.Millisecond {
+					t.Errorf("Jobs.PollInterval = %v, want 250ms", cfg.Jobs.PollInterval)
+				}
+				if cfg.Database.MigrateOnStart {
+					t.Error("Database.MigrateOnStart = true, want false")
+				}
+				if cfg.Currency.Default != "USD" {
+					t.Errorf("Currency.Default = %q, want USD", cfg.Currency.Default)
+				}
+				if cfg.Log.Level != "debug" {
+					t.Errorf("Log.Level = %q, want debug", cfg.Log.Level)
+				}
+			},
+		},
+		{
+			name: "file under environment",
+			env:  map[string]string{"PORT": "9090"},
+			file: "server:\n  port: 7070\n  idle_timeout: 1m\ndatabase:\n  url: postgres://db/products\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != 9090 {
+					t.Errorf("Port = %d, want 9090 from the environment", cfg.Server.Port)
+				}
+				if cfg.Server.IdleTimeout != time.Minute {
+					t.Errorf("Server.IdleTimeout = %v, want 1m from the file", cfg.Server.IdleTimeout)
+				}
+				if cfg.Database.URL != "postgres://db/products" {
+					t.Errorf("Database.URL = %q, want the file's", cfg.Database.URL)
+				}
+			},
+		},
+		{
+			name:     "unknown file key",
+			file:     "server:\n  prot: 7070\n",
+			wantErrs: []string{"CONFIG_FILE", "prot"},
+		},
+		{
+			name:     "missing database",
+			wantErrs: []string{"DATABASE_URL is required"},
+		},
+		{
+			name: "unparsable values",
+			env: map[string]string{
+				"DATABASE_URL":     "postgres://localhost/products",
+				"PORT":             "http",
+				"HANDLER_TIMEOUT":  "soon",
+				"MIGRATE_ON_START": "maybe",
+			},
+			wantErrs: []string{
+				`PORT: invalid integer "http"`,
+				`HANDLER_TIMEOUT: invalid duration "soon"`,
+				`MIGRATE_ON_START: invalid boolean "maybe"`,
+			},
+		},
+		{
+			name: "invalid values",
+			env: map[string]string{
+				"PORT":                 "70000",
+				"LOG_LEVEL":            "loud",
+				"DEFAULT_CURRENCY":     "dollars",
+				"EXCHANGE_RATES":       "USD",
+				"JOB_WORKERS":          "0",
+				"HANDLER_BULK_TIMEOUT": "-1s",
+				"MAX_BODY_BYTES":       "-1",
+			},
+			wantErrs: []string{
+				"PORT must be between 1 and 65535, got 70000",
+				`LOG_LEVEL must be debug, info, warn or error, got "loud"`,
+				"DATABASE_URL is required",
+				"DEFAULT_CURRENCY must be a 3-letter currency code",
+				"EXCHANGE_RATES:",
+				"JOB_WORKERS must be positive",
+				"HANDLER_BULK_TIMEOUT must be positive",
+				"MAX_BODY_BYTES must be positive",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			cfg, err := Load()
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("Load() succeeded, want errors %q", tt.wantErrs)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Load() error = %q, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := defaults()
+	cfg.Database.URL = "postgres://products:s3cret@db:5432/products?sslmode=disable"
+	cfg.Auth.JWTSecret = "0123456789abcdef0123456789abcdef"
+	cfg.Auth.JWKSURL = "https://idp.example.com/.well-known/jwks.json"
+
+	got := cfg.Redacted()
+
+	tests := []struct {
+		path []string
+		want any
+	}{
+		{[]string{"database", "url"}, "postgres://products:xxxxx@db:5432/products?sslmode=disable"},
+		{[]string{"auth", "jwt_secret"}, redacted},
+		// Unset secrets show they are unset
+		{[]string{"auth", "api_keys"}, ""},
+		{[]string{"auth", "jwks_url"}, "https://idp.example.com/.well-known/jwks.json"},
+		{[]string{"server", "port"}, 8080},
+		{[]string{"server", "read_timeout"}, "30s"},
+		{[]string{"idempotency_ttl"}, "24h0m0s"},
+	}
+
+	for _, tt := range tests {
+		var v any = got
+		for _, key := range tt.path {
+			v = v.(map[string]any)[key]
+		}
+		if v != tt.want {
+			t.Errorf("Redacted()[%s] = %#v, want %#v", strings.Join(tt.path, "."), v, tt.want)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"postgres://user:pass@db:5432/app", "postgres://user:xxxxx@db:5432/app"},
+		{"postgres://user@db/app", "postgres://user@db/app"},
+		{"postgres://db/app?user=u&password=pass", "postgres://db/app?password=xxxxx&user=u"},
+		{"host=db user=u password=pass dbname=app", redacted},
+		{"db:5432", redacted},
+	}
+
+	for _, tt := range tests {
+		if got := redactURL(tt.raw); got != tt.want {
+			t.Errorf("redactURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		file    string
+		wantURL string
+		wantErr string
+	}{
+		{
+			name: "other settings are ignored",
+			env: map[string]string{
+				"DATABASE_URL":     "postgres://localhost/products",
+				"PORT":             "http",
+				"SHUTDOWN_TIMEOUT": "-1s",
+			},
+			wantURL: "postgres://localhost/products",
+		},
+		{
+			name:    "database from the file",
+			file:    "server:\n  port: 0\ndatabase:\n  url: postgres://db/products\n",
+			wantURL: "postgres://db/products",
+		},
+		{
+			name:    "missing database",
+			env:     map[string]string{"PORT": "9090"},
+			wantErr: "DATABASE_URL is required",
+		},
+		{
+			name:    "invalid log level",
+			env:     map[string]string{"DATABASE_URL": "postgres://localhost/products", "LOG_LEVEL": "loud"},
+			wantErr: "LOG_LEVEL must be debug, info, warn or error",
+		},
+		{
+			name:    "unparsable database setting",
+			env:     map[string]string{"DATABASE_URL": "postgres://localhost/products", "MIGRATE_ON_START": "maybe"},
+			wantErr: `MIGRATE_ON_START: invalid boolean "maybe"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			cfg, err := LoadDatabase()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadDatabase() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDatabase() error = %v", err)
+			}
+			if cfg.Database.URL != tt.wantURL {
+				t.Errorf("Database.URL = %q, want %q", cfg.Database.URL, tt.wantURL)
+			}
+		})
+	}
+}
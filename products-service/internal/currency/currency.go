@@ -0,0 +1,94 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rates is the local exchange-rate table. Every rate is expressed against the
+// same base, e.g. USD=1,SAR=3.75,AED=3.6725.
+type Rates map[string]float64
+
+// ParseRates reads a rate table such as "USD=1,SAR=3.75,AED=3.6725".
+// An empty table disables fallback conversion.
+func ParseRates(raw string) (Rates, error) {
+	rates := Rates{}
+
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return rates, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		code, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q", pair)
+		}
+
+		code = Normalize(code)
+		if !Valid(code) {
+			return nil, fmt.Errorf("invalid currency code %q", code)
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s: %q", code, value)
+		}
+
+		rates[code] = rate
+	}
+
+	return rates, nil
+}
+
+// Normalize upper-cases and trims a currency code.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Valid reports whether code looks like an ISO 4217 code.
+func Valid(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Convert converts amount between two currencies using the table.
+func (r Rates) Convert(amount float64, from, to string) (float64, bool) {
+	if from == to {
+		return amount, true
+	}
+
+	factor, ok := r.Factor(from, to)
+	if !ok {
+		return 0, false
+	}
+
+	return Round(amount * factor), true
+}
+
+// Factor returns the multiplier that converts an amount in from into to.
+func (r Rates) Factor(from, to string) (float64, bool) {
+	fromRate, ok := r[from]
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := r[to]
+	if !ok {
+		return 0, false
+	}
+	return toRate / fromRate, true
+}
+
+// Round rounds an amount to two decimals.
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -2,22 +2,50 @@ package database
 
 import (
 	"log"
-	"os"
+	"time"
 
+	"github.com/MosaabBleik/products-service/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-func Connect() *gorm.DB {
-	dsn := os.Getenv("DATABASE_URL")
-
+// Connect opens the database at dsn.
+func Connect(dsn string) *gorm.DB {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		PrepareStmt: true,
+		PrepareStmt:    true,
+		TranslateError: true,
 	})
 
 	if err != nil {
 		log.Fatal("Failed to connect to DB:", err)
 	}
 
+	// Queries run with a request context join its trace
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+		log.Fatal("Failed to instrument DB:", err)
+	}
+
 	return db
 }
+
+// Close closes the connections of db.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// FailInterruptedImports marks imports left unfinished by a previous run as
+// failed, since their uploaded files are gone.
+func FailInterruptedImports(db *gorm.DB) error {
+	return db.Model(&models.ProductImport{}).
+		Where("status IN ?", []string{models.ImportStatusPending, models.ImportStatusRunning}).
+		Updates(map[string]any{
+			"status":      models.ImportStatusFailed,
+			"error":       "interrupted by a restart",
+			"finished_at": time.Now(),
+		}).Error
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/MosaabBleik/products-service/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// MigrationStatus is one migration and whether it is applied.
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// Migrator opens the embedded SQL migrations against the database at dsn. The
+// postgres driver holds an advisory lock while migrating, so replicas that
+// start together apply each migration once.
+func Migrator(dsn string) (*migrate.Migrate, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithSourceInstance("iofs", src, dsn)
+}
+
+// MigrateUp applies every pending migration.
+func MigrateUp(dsn string) error {
+	m, err := Migrator(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Migrations lists the known migrations against the current version.
+func Migrations(m *migrate.Migrate) (current uint, dirty bool, list []MigrationStatus, err error) {
+	current, dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil, err
+	}
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return 0, false, nil, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	for err == nil {
+		r, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return 0, false, nil, readErr
+		}
+		r.Close()
+
+		list = append(list, MigrationStatus{Version: version, Name: name, Applied: version <= current})
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, false, nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	return current, dirty, list, nil
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"gorm.io/gorm"
+)
+
+// ancestorSchemasQuery selects the attribute schemas of a category of a tenant
+// and its ancestors, root first.
+const ancestorSchemasQuery = `WITH RECURSIVE chain AS (
+	SELECT id, parent_id, attributes, 0 AS depth FROM categories WHERE tenant_id = ? AND slug = ?
+	UNION ALL
+	SELECT c.id, c.parent_id, c.attributes, chain.depth + 1 FROM categories c JOIN chain ON c.id = chain.parent_id
+) SELECT attributes FROM chain ORDER BY depth DESC`
+
+var (
+	attributeNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	attrFilterPattern    = regexp.MustCompile(`^attr\.([a-z0-9_]+)(>=|<=|!=|>|<|=)(.*)$`)
+)
+
+type attrFilter struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+// validateSchema checks the attribute definitions of a category.
+func validateSchema(schema models.AttributeSchema) error {
+	seen := make(map[string]bool, len(schema))
+	for _, def := range schema {
+		if !attributeNamePattern.MatchString(def.Name) {
+			return fmt.Errorf("invalid attribute name %q, use lowercase letters, digits and _", def.Name)
+		}
+		if seen[def.Name] {
+			return fmt.Errorf("duplicate attribute %q", def.Name)
+		}
+		seen[def.Name] = true
+
+		switch def.Type {
+		case models.AttributeString, models.AttributeNumber, models.AttributeBoolean:
+		default:
+			return fmt.Errorf("attribute %q has invalid type %q", def.Name, def.Type)
+		}
+		if len(def.Values) > 0 && def.Type != models.AttributeString {
+			return fmt.Errorf("attribute %q: values are only allowed for string attributes", def.Name)
+		}
+	}
+	return nil
+}
+
+// categorySchema returns the attribute schema of a category merged with the
+// schemas of its ancestors. Definitions of a subcategory win.
+func categorySchema(db *gorm.DB, tenantID, slug string) (map[string]models.AttributeDef, error) {
+	var rows []struct {
+		Attributes models.AttributeSchema
+	}
+	if err := db.Raw(ancestorSchemasQuery, tenantID, slug).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	schema := make(map[string]models.AttributeDef)
+	for _, row := range rows {
+		for _, def := range row.Attributes {
+			schema[def.Name] = def
+		}
+	}
+	return schema, nil
+}
+
+// validateAttributes checks product attributes against the schema of its
+// category. Categories without a schema accept any scalar attributes.
+func validateAttributes(attrs models.JSONMap, schema map[string]models.AttributeDef) error {
+	for name, value := range attrs {
+		if !attributeNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid attribute name %q, use lowercase letters, digits and _", name)
+		}
+
+		if len(schema) == 0 {
+			switch value.(type) {
+			case string, float64, bool:
+				continue
+			default:
+				return fmt.Errorf("attribute %q must be a string, number or boolean", name)
+			}
+		}
+
+		def, ok := schema[name]
+		if !ok {
+			return fmt.Errorf("attribute %q is not defined for this category", name)
+		}
+
+		switch def.Type {
+		case models.AttributeString:
+			s, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("attribute %q must be a string", name)
+			}
+			if len(def.Values) > 0 && !slices.Contains(def.Values, s) {
+				return fmt.Errorf("attribute %q must be one of %s", name, strings.Join(def.Values, ", "))
+			}
+		case models.AttributeNumber:
+			if _, ok := value.(float64); !ok {
+				return fmt.Errorf("attribute %q must be a number", name)
+			}
+		case models.AttributeBoolean:
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("attribute %q must be a boolean", name)
+			}
+		}
+	}
+
+	for name, def := range schema {
+		if _, ok := attrs[name]; def.Required && !ok {
+			return fmt.Errorf("attribute %q is required", name)
+		}
+	}
+
+	return nil
+}
+
+// parseAttrFilters reads attribute filters such as attr.ram_gb>=16 and
+// attr.color=black from the raw query string, sorted for stable cache keys.
+func parseAttrFilters(rawQuery string) ([]attrFilter, error) {
+	var filters []attrFilter
+
+	for _, part := range strings.Split(rawQuery, "&") {
+		part, err := url.QueryUnescape(part)
+		if err != nil || !strings.HasPrefix(part, "attr.") {
+			continue
+		}
+
+		m := attrFilterPattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("invalid attribute filter %q", part)
+		}
+
+		f := attrFilter{Name: m[1], Op: m[2], Value: m[3]}
+		if f.Op != "=" && f.Op != "!=" {
+			if _, err := strconv.ParseFloat(f.Value, 64); err != nil {
+				return nil, fmt.Errorf("attribute filter %q needs a number", part)
+			}
+		}
+		filters = append(filters, f)
+	}
+
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].String() < filters[j].String()
+	})
+	return filters, nil
+}
+
+func (f attrFilter) String() string {
+	return f.Name + f.Op + f.Value
+}
+
+// attrFilterScope applies attribute filters. Equality uses jsonb containment
+// so it is served by the GIN index.
+func attrFilterScope(filters []attrFilter) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		for _, f := range filters {
+			switch f.Op {
+			case "=", "!=":
+				cond, vars := containment(f)
+				if f.Op == "!=" {
+					cond = "NOT " + cond
+				}
+				query = query.Where(cond, vars...)
+			default:
+				value, _ := strconv.ParseFloat(f.Value, 64)
+				query = query.Where(
+					"(CASE WHEN jsonb_typeof(attributes -> ?::text) = 'number' THEN (attributes ->> ?::text)::numeric END) "+f.Op+" ?",
+					f.Name, f.Name, value,
+				)
+			}
+		}
+		return query
+	}
+}
+
+// containment builds an attributes @> condition. Values that look like numbers
+// or booleans also match their string form.
+func containment(f attrFilter) (string, []any) {
+	asString, _ := json.Marshal(map[string]any{f.Name: f.Value})
+
+	var typed any
+	if n, err := strconv.ParseFloat(f.Value, 64); err == nil {
+		typed = n
+	} else if f.Value == "true" || f.Value == "false" {
+		typed = f.Value == "true"
+	}
+
+	if typed == nil {
+		return "(attributes @> ?::jsonb)", []any{string(asString)}
+	}
+
+	asTyped, _ := json.Marshal(map[string]any{f.Name: typed})
+	return "(attributes @> ?::jsonb OR attributes @> ?::jsonb)", []any{string(asTyped), string(asString)}
+}
+
+// validateAttributes checks product attributes against the schema of a
+// category of a tenant.
+func (h *ProductHandler) validateAttributes(db *gorm.DB, tenantID, category string, attrs models.JSONMap) error {
+	schema, err := categorySchema(db, tenantID, category)
+	if err != nil {
+		return err
+	}
+	if err := validateAttributes(attrs, schema); err != nil {
+		return invalidAttributesError{err}
+	}
+	return nil
+}
+
+type invalidAttributesError struct {
+	error
+}
+
+func writeAttributesError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid invalidAttributesError
+	if errors.As(err, &invalid) {
+		problem.Write(w, http.StatusBadRequest, "invalid_attributes", err.Error())
+		return
+	}
+
+	writeInternalError(w, r, "Failed to validate attributes", err)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/middleware"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// changeFromRequest identifies a product change made through r.
+func changeFromRequest(r *http.Request, action string) audit.Change {
+	return audit.Change{
+		Tenant:    tenant.FromContext(r.Context()),
+		Action:    action,
+		Actor:     actorFromRequest(r),
+		RequestID: middleware.RequestIDFrom(r.Context()),
+	}
+}
+
+// ProductAudit lists the changes of a product, newest first. The trail of a
+// deleted product stays available.
+func (h *ProductHandler) ProductAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	tenantID := tenant.FromContext(r.Context())
+	page, limit := getPaginationParams(r)
+
+	query := h.DB.WithContext(r.Context()).Model(&models.AuditEntry{}).Where("tenant_id = ? AND product_id = ?", tenantID, id)
+	if action := r.URL.Query().Get("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	// Shared by the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch audit trail", err)
+		return
+	}
+
+	// A product without any entry may still exist, e.g. from before auditing
+	if total == 0 {
+		if err := h.DB.WithContext(r.Context()).Select("id").Scopes(tenantProducts(tenantID)).Where("id = ?", id).First(&models.Product{}).Error; err != nil {
+			writeLookupError(w, r, err, "product_not_found", "Product not found")
+			return
+		}
+	}
+
+	var entries []models.AuditEntry
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch audit trail", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"product_id": id,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"count":      len(entries),
+		"entries":    entries,
+	})
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// descendantsQuery selects the slug of a category of a tenant and of all its
+// descendants.
+const descendantsQuery = `WITH RECURSIVE tree AS (
+	SELECT id, slug FROM categories WHERE tenant_id = ? AND slug = ?
+	UNION ALL
+	SELECT c.id, c.slug FROM categories c JOIN tree t ON c.parent_id = t.id
+) SELECT slug FROM tree`
+
+// descendantIDsQuery selects the id of a category and of all its descendants.
+const descendantIDsQuery = `WITH RECURSIVE tree AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION ALL
+	SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+) SELECT id FROM tree`
+
+var (
+	errCategoryRequired = errors.New("category is required")
+	errCategoryNotFound = errors.New("category not found")
+)
+
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+type categoryRequest struct {
+	Name       string                 `json:"name"`
+	Slug       string                 `json:"slug"`
+	Parent     string                 `json:"parent"`
+	Attributes models.AttributeSchema `json:"attributes"`
+}
+
+type CategoryHandler struct {
+	DB          *gorm.DB
+	RedisClient *redis.Client
+}
+
+// resolveCategory maps user input ("Electronics", "electronics") to the slug
+// of an existing category of a tenant.
+func resolveCategory(db *gorm.DB, tenantID, input string) (string, error) {
+	slug := models.Slugify(input)
+	if slug == "" {
+		return "", errCategoryRequired
+	}
+
+	var category models.Category
+	err := db.Select("slug").Scopes(tenantCategories(tenantID)).Where("slug = ?", slug).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("%w: %s", errCategoryNotFound, slug)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return category.Slug, nil
+}
+
+// tenantCategories limits a query to the categories of a tenant.
+func tenantCategories(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("categories.tenant_id = ?", tenantID)
+	}
+}
+
+// categoryScope restricts a products query to a category of a tenant and its
+// descendants.
+func categoryScope(db *gorm.DB, tenantID, input string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("category IN (?)", db.Raw(descendantsQuery, tenantID, models.Slugify(input)))
+	}
+}
+
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var categories []models.Category
+	if err := h.DB.WithContext(r.Context()).Scopes(tenantCategories(tenant.FromContext(r.Context()))).Order("name ASC").Find(&categories).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch categories", err)
+		return
+	}
+
+	if r.URL.Query().Get("tree") != "true" {
+		json.NewEncoder(w).Encode(map[string]any{
+			"count":      len(categories),
+			"categories": categories,
+		})
+		return
+	}
+
+	// Build the tree from the flat list
+	nodes := make(map[string]*CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &CategoryNode{Category: c, Children: []*CategoryNode{}}
+	}
+
+	roots := make([]*CategoryNode, 0)
+	for _, c := range categories {
+		node := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"count":      len(categories),
+		"categories": roots,
+	})
+}
+
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["slug"]
+
+	category, err := h.findCategory(r, slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, "category_not_found", "Category not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to fetch category", err)
+		return
+	}
+
+	var children []models.Category
+	if err := h.DB.WithContext(r.Context()).Where("parent_id = ?", category.ID).Order("name ASC").Find(&children).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch subcategories", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"category": category,
+		"children": children,
+	})
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	category := models.Category{TenantID: tenant.FromContext(r.Context()), Name: req.Name}
+	if err := h.apply(r.Context(), &category, req); err != nil {
+		h.writeCategoryError(w, r, err)
+		return
+	}
+
+	if err := h.DB.WithContext(r.Context()).Create(&category).Error; err != nil {
+		h.writeCategoryError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["slug"]
+
+	var req categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	category, err := h.findCategory(r, slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, "category_not_found", "Category not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to fetch category", err)
+		return
+	}
+
+	oldSlug := category.Slug
+	category.Name = req.Name
+	if err := h.apply(r.Context(), &category, req); err != nil {
+		h.writeCategoryError(w, r, err)
+		return
+	}
+
+	// Products of the tenant reference categories by slug
+	err = h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		if category.Slug != oldSlug {
+			change := changeFromRequest(r, audit.ActionCategoryRename)
+			if err := audit.RecordCategoryRename(tx, oldSlug, category.Slug, change); err != nil {
+				return err
+			}
+			return tx.Model(&models.Product{}).
+				Scopes(tenantProducts(category.TenantID)).
+				Where("category = ?", oldSlug).
+				Updates(map[string]any{
+					"category": category.Slug,
+					"version":  gorm.Expr("version + 1"),
+				}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		h.writeCategoryError(w, r, err)
+		return
+	}
+
+	cache.InvalidateSearch(r.Context(), h.RedisClient, category.TenantID)
+
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["slug"]
+
+	category, err := h.findCategory(r, slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, "category_not_found", "Category not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to fetch category", err)
+		return
+	}
+
+	var children, products int64
+	if err := h.DB.WithContext(r.Context()).Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		writeInternalError(w, r, "Failed to count subcategories", err)
+		return
+	}
+	if err := h.DB.WithContext(r.Context()).Model(&models.Product{}).Scopes(tenantProducts(category.TenantID)).Where("category = ?", category.Slug).Count(&products).Error; err != nil {
+		writeInternalError(w, r, "Failed to count products", err)
+		return
+	}
+	if children > 0 || products > 0 {
+		problem.New(http.StatusConflict, "category_in_use", "Category is still in use").
+			With("subcategories", children).
+			With("products", products).
+			Write(w)
+		return
+	}
+
+	if err := h.DB.WithContext(r.Context()).Delete(&category).Error; err != nil {
+		writeInternalError(w, r, "Failed to delete category", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Category deleted successfully"))
+}
+
+// findCategory returns the category of the tenant of r with slug.
+func (h *CategoryHandler) findCategory(r *http.Request, slug string) (models.Category, error) {
+	var category models.Category
+	err := h.DB.WithContext(r.Context()).
+		Scopes(tenantCategories(tenant.FromContext(r.Context()))).
+		Where("slug = ?", slug).
+		First(&category).Error
+	return category, err
+}
+
+// apply validates the request and sets slug and parent on category. The
+// parent is looked up among the categories of the same tenant.
+func (h *CategoryHandler) apply(ctx context.Context, category *models.Category, req categoryRequest) error {
+	if category.Name == "" {
+		return errInvalidCategory("name is required")
+	}
+
+	category.Slug = models.Slugify(req.Slug)
+	if category.Slug == "" {
+		category.Slug = models.Slugify(category.Name)
+	}
+	if category.Slug == "" {
+		return errInvalidCategory("slug must contain letters or digits")
+	}
+
+	// The attribute schema is kept when omitted
+	if req.Attributes != nil {
+		if err := validateSchema(req.Attributes); err != nil {
+			return errInvalidCategory(err.Error())
+		}
+		category.Attributes = req.Attributes
+	}
+
+	category.ParentID = nil
+	if req.Parent == "" {
+		return nil
+	}
+
+	var parent models.Category
+	err := h.DB.WithContext(ctx).Scopes(tenantCategories(category.TenantID)).Where("slug = ?", models.Slugify(req.Parent)).First(&parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errInvalidCategory("parent category not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	// A category cannot be moved below itself or one of its descendants
+	if category.ID != "" {
+		var descendants []string
+		if err := h.DB.WithContext(ctx).Raw(descendantIDsQuery, category.ID).Scan(&descendants).Error; err != nil {
+			return err
+		}
+		for _, id := range descendants {
+			if id == parent.ID {
+				return errInvalidCategory("parent would create a cycle")
+			}
+		}
+	}
+
+	category.ParentID = &parent.ID
+	return nil
+}
+
+// writeCategoryResolveError reports a failed resolveCategory on a product write.
+func writeCategoryResolveError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errCategoryRequired) || errors.Is(err, errCategoryNotFound) {
+		problem.Write(w, http.StatusBadRequest, "invalid_category", err.Error())
+		return
+	}
+
+	writeInternalError(w, r, "Failed to validate category", err)
+}
+
+type errInvalidCategory string
+
+func (e errInvalidCategory) Error() string {
+	return string(e)
+}
+
+func (h *CategoryHandler) writeCategoryError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid errInvalidCategory
+	switch {
+	case errors.As(err, &invalid):
+		problem.Write(w, http.StatusBadRequest, "invalid_category", err.Error())
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		problem.Write(w, http.StatusConflict, "category_slug_taken", "category slug already exists")
+	default:
+		writeInternalError(w, r, "Failed to save category", err)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheControl holds the Cache-Control values sent on product reads.
+type CacheControl struct {
+	Product string
+	List    string
+	Search  string
+}
+
+// bodyETag is a weak entity tag over a rendered response body.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag using
+// the weak comparison of RFC 9110.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified evaluates If-None-Match and, only when it is absent,
+// If-Modified-Since. A zero lastModified disables the date check.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// setValidators sets the caching headers shared by 200 and 304 responses.
+func setValidators(w http.ResponseWriter, etag string, lastModified time.Time, cacheControl string) {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+}
+
+// writeNotModified answers a successful revalidation. Content-Type is
+// dropped since a 304 has no body.
+func writeNotModified(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// writeCacheable writes a rendered list body with a weak ETag, or 304 when
+// the client already has it.
+func writeCacheable(w http.ResponseWriter, r *http.Request, body []byte, cacheControl string) {
+	setValidators(w, bodyETag(body), time.Time{}, cacheControl)
+	if notModified(r, bodyETag(body), time.Time{}) {
+		writeNotModified(w)
+		return
+	}
+	w.Write(body)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyETag(t *testing.T) {
+	a, b := bodyETag([]byte(`{"data":[]}`)), bodyETag([]byte(`{"data":[1]}`))
+	if !strings.HasPrefix(a, `W/"`) || !strings.HasSuffix(a, `"`) {
+		t.Errorf("bodyETag() = %s, want a weak tag", a)
+	}
+	if a == b {
+		t.Errorf("bodyETag() = %s for different bodies", a)
+	}
+	if again := bodyETag([]byte(`{"data":[]}`)); again != a {
+		t.Errorf("bodyETag() = %s, then %s for the same body", a, again)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{`"3"`, `"3"`, true},
+		{`"2", "3"`, `"3"`, true},
+		{` "2" ,"3" `, `"3"`, true},
+		{`*`, `"3"`, true},
+		{`W/"3"`, `"3"`, true},
+		{`"abc"`, `W/"abc"`, true},
+		{`W/"abc"`, `W/"abc"`, true},
+		{`"3-USD"`, `"3-USD"`, true},
+		{`"2"`, `"3"`, false},
+		{`"3"`, `"3-USD"`, false},
+		{`3`, `"3"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, tt.etag); got != tt.want {
+			t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	at := func(d time.Duration) string { return modified.Add(d).Format(http.TimeFormat) }
+
+	tests := []struct {
+		name         string
+		method       string
+		ifNoneMatch  string
+		ifModified   string
+		lastModified time.Time
+		want         bool
+	}{
+		{name: "no validators", lastModified: modified, want: false},
+		{name: "matching etag", ifNoneMatch: `"3"`, lastModified: modified, want: true},
+		{name: "matching etag on HEAD", method: http.MethodHead, ifNoneMatch: `"3"`, want: true},
+		{name: "other etag", ifNoneMatch: `"2"`, lastModified: modified, want: false},
+		{name: "etag wins over date", ifNoneMatch: `"2"`, ifModified: at(time.Hour), lastModified: modified, want: false},
+		{name: "unmodified since", ifModified: at(0), lastModified: modified, want: true},
+		{name: "unmodified since later", ifModified: at(time.Hour), lastModified: modified, want: true},
+		{name: "modified since", ifModified: at(-time.Hour), lastModified: modified, want: false},
+		{name: "date without last modified", ifModified: at(time.Hour), want: false},
+		{name: "invalid date", ifModified: "yesterday", lastModified: modified, want: false},
+		{name: "not a read", method: http.MethodPut, ifNoneMatch: `"3"`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/api/products/1", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModified != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModified)
+			}
+
+			if got := notModified(req, `"3"`, tt.lastModified); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCacheable(t *testing.T) {
+	body := []byte(`{"data":[]}`)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    string
+	}{
+		{name: "first read", wantStatus: http.StatusOK, wantBody: string(body)},
+		{name: "revalidated", ifNoneMatch: bodyETag(body), wantStatus: http.StatusNotModified},
+		{name: "changed", ifNoneMatch: bodyETag([]byte(`{"data":[1]}`)), wantStatus: http.StatusOK, wantBody: string(body)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", "application/json")
+
+			writeCacheable(rec, req, body, "no-cache")
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("ETag"); got != bodyETag(body) {
+				t.Errorf("ETag = %s, want %s", got, bodyETag(body))
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-cache" {
+				t.Errorf("Cache-Control = %q, want no-cache", got)
+			}
+			if ct := rec.Header().Get("Content-Type"); (ct == "") != (tt.wantStatus == http.StatusNotModified) {
+				t.Errorf("Content-Type = %q on a %d", ct, rec.Code)
+			}
+		})
+	}
+}
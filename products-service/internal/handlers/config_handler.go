@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/MosaabBleik/products-service/internal/config"
+)
+
+type ConfigHandler struct {
+	Config *config.Config
+}
+
+// GetConfig returns the effective configuration with secrets redacted.
+func (h *ConfigHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	json.NewEncoder(w).Encode(h.Config.Redacted())
+}
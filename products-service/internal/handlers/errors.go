@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"gorm.io/gorm"
+)
+
+// writeInternalError logs err, which may hold details such as database
+// messages, and answers with message only. Work cut short by the request
+// timeout answers with a 503.
+func writeInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "error", err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		problem.Write(w, http.StatusServiceUnavailable, "request_timeout", "The request took too long and was stopped")
+		return
+	}
+	problem.Write(w, http.StatusInternalServerError, problem.Code(http.StatusInternalServerError), message)
+}
+
+// writeLookupError answers a failed lookup of one record: 404 with code and
+// detail when the record does not exist, writeInternalError otherwise.
+func writeLookupError(w http.ResponseWriter, r *http.Request, err error, code, detail string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, code, detail)
+		return
+	}
+	writeInternalError(w, r, "Failed to look up "+strings.ToLower(strings.TrimSuffix(detail, " not found")), err)
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, http.StatusNotFound, "route_not_found", "No route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers requests to a route that does not accept their
+// method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method "+r.Method+" is not allowed on "+r.URL.Path)
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/MosaabBleik/products-service/internal/problem"
+)
+
+// exportBatchSize is how many products are read from the database at a time.
+const exportBatchSize = 500
+
+// exportFields are the columns of an export, in output order.
+var exportFields = []string{
+	"id", "external_sku", "name", "description", "price", "currency",
+	"category", "status", "attributes", "version", "created_at", "updated_at",
+}
+
+// parseExportFields reads the optional fields= list, keeping the order of
+// exportFields.
+func parseExportFields(r *http.Request) ([]string, error) {
+	param := r.URL.Query().Get("fields")
+	if param == "" {
+		return exportFields, nil
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range strings.Split(param, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(exportFields, name) {
+			return nil, fmt.Errorf("unknown field %q, use %s", name, strings.Join(exportFields, ", "))
+		}
+		requested[name] = true
+	}
+
+	fields := make([]string, 0, len(requested))
+	for _, name := range exportFields {
+		if requested[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// exportValue returns a field of p. Prices are in the requested currency.
+func exportValue(p pricedProduct, cur, field string) any {
+	switch field {
+	case "id":
+		return p.ID
+	case "external_sku":
+		return p.ExternalSKU
+	case "name":
+		return p.Name
+	case "description":
+		return p.Description
+	case "price":
+		return p.EffectivePrice
+	case "currency":
+		if cur != "" {
+			return cur
+		}
+		return p.Currency
+	case "category":
+		return p.Category
+	case "status":
+		return p.Status
+	case "attributes":
+		return p.Attributes
+	case "version":
+		return p.Version
+	case "created_at":
+		return p.CreatedAt
+	case "updated_at":
+		return p.UpdatedAt
+	}
+	return nil
+}
+
+// csvValue renders an export value as a CSV cell.
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// productEncoder writes exported products in one format.
+type productEncoder interface {
+	Encode(p pricedProduct) error
+	Flush() error
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	fields []string
+	cur    string
+	record []string
+}
+
+// newCSVEncoder buffers the header row, write errors surface on Flush.
+func newCSVEncoder(w io.Writer, fields []string, cur string) *csvEncoder {
+	e := &csvEncoder{w: csv.NewWriter(w), fields: fields, cur: cur, record: make([]string, len(fields))}
+	e.w.Write(fields)
+	return e
+}
+
+func (e *csvEncoder) Encode(p pricedProduct) error {
+	for i, field := range e.fields {
+		e.record[i] = csvValue(exportValue(p, e.cur, field))
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc    *json.Encoder
+	fields []string
+	cur    string
+}
+
+func (e *ndjsonEncoder) Encode(p pricedProduct) error {
+	row := make(map[string]any, len(e.fields))
+	for _, field := range e.fields {
+		row[field] = exportValue(p, e.cur, field)
+	}
+	return e.enc.Encode(row)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
+
+// ExportProducts streams every product matching the search filters as CSV or
+// NDJSON. Products are read in batches in ID order, so memory stays flat.
+func (h *ProductHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = importFormatNDJSON
+	}
+	if format != importFormatCSV && format != importFormatNDJSON {
+		w.Header().Set("Content-Type", "application/json")
+		problem.Write(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("unsupported format %q, use csv or ndjson", format))
+		return
+	}
+
+	fields, err := parseExportFields(r)
+	if err == nil && len(fields) == 0 {
+		err = fmt.Errorf("fields must name at least one of %s", strings.Join(exportFields, ", "))
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	filters, err := parseSearchFilters(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	// Large catalogs take longer to stream than the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// Headers are only sent with the first batch, so a failing query can
+	// still answer with an error
+	out := &exportWriter{w: w}
+	var body io.Writer = out
+	var gz *gzip.Writer
+	if acceptsGzip(r) {
+		gz = gzip.NewWriter(out)
+		body = gz
+	}
+
+	var encoder productEncoder = &ndjsonEncoder{enc: json.NewEncoder(body), fields: fields, cur: filters.Currency}
+	if format == importFormatCSV {
+		encoder = newCSVEncoder(body, fields, filters.Currency)
+	}
+
+	out.start = func() {
+		contentType := "application/x-ndjson"
+		if format == importFormatCSV {
+			contentType = "text/csv; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+		w.Header().Add("Vary", "Accept-Encoding")
+		if gz != nil {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+
+	var products []pricedProduct
+	err = h.searchQuery(filters).WithContext(r.Context()).FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, p := range products {
+			if err := encoder.Encode(p); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		return nil
+	}).Error
+
+	if err != nil && !out.started {
+		w.Header().Set("Content-Type", "application/json")
+		writeInternalError(w, r, "Failed to export products", err)
+		return
+	}
+	if err != nil {
+		// The status is already sent, the client sees a truncated file
+		slog.ErrorContext(r.Context(), "export failed", "error", err)
+		return
+	}
+
+	// An empty export still gets its CSV header and gzip footer
+	if err := encoder.Flush(); err == nil && gz != nil {
+		gz.Close()
+	}
+	out.Flush()
+}
+
+// exportWriter sends the response headers on the first write.
+type exportWriter struct {
+	w       http.ResponseWriter
+	start   func()
+	started bool
+}
+
+func (e *exportWriter) Write(b []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		e.start()
+	}
+	return e.w.Write(b)
+}
+
+func (e *exportWriter) Flush() {
+	if !e.started {
+		e.started = true
+		e.start()
+	}
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
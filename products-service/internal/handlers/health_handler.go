@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readyTimeout bounds each dependency check of a readiness probe
+const readyTimeout = 2 * time.Second
+
+// dependencyStatus is the result of checking one dependency.
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func checkDependency(ctx context.Context, check func(context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := dependencyStatus{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		status.Status = "error"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Live reports that the process is up. It checks no dependency, so an
+// outage of one does not get the service restarted.
+func (h *ProductHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Ready reports whether the database and Redis are usable, with 503 when
+// either is not.
+func (h *ProductHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	var db, redis dependencyStatus
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		db = checkDependency(r.Context(), func(ctx context.Context) error {
+			return h.DB.WithContext(ctx).Exec("SELECT 1").Error
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		redis = checkDependency(r.Context(), func(ctx context.Context) error {
+			return h.RedisClient.Ping(ctx).Err()
+		})
+	}()
+	wg.Wait()
+
+	status := "ready"
+	if db.Status != "ok" || redis.Status != "ok" {
+		status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"checks": map[string]dependencyStatus{
+			"database": db,
+			"redis":    redis,
+		},
+	})
+}
@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/currency"
+	"github.com/MosaabBleik/products-service/internal/middleware"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/pricing"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	importFormatCSV    = "csv"
+	importFormatNDJSON = "ndjson"
+
+	maxImportBytes = 100 << 20
+	// maxImportLine bounds a single NDJSON line
+	maxImportLine = 1 << 20
+	// maxImportReport caps the errors and changes kept on an import
+	maxImportReport = 1000
+	// importProgressEvery is how many rows are processed between progress updates
+	importProgressEvery = 100
+)
+
+var errImportRowInvalid = errors.New("invalid row")
+
+// importRow is one product of an import file. Rows are matched to products
+// by their external SKU.
+type importRow struct {
+	ExternalSKU string         `json:"external_sku"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Price       *float64       `json:"price"`
+	Currency    string         `json:"currency"`
+	Category    string         `json:"category"`
+	Status      string         `json:"status"`
+	Attributes  models.JSONMap `json:"attributes"`
+}
+
+// rowReader yields the rows of an import file with their line numbers. A
+// rowError fails only that row, any other error aborts the import.
+type rowReader func() (row importRow, line int, err error)
+
+type rowError struct {
+	error
+}
+
+func normalizeExternalSKU(sku *string) *string {
+	if sku == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// importFormat picks the file format from ?format= or the Content-Type.
+func importFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = importFormatCSV
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			format = importFormatNDJSON
+		}
+	}
+
+	switch format {
+	case importFormatCSV, importFormatNDJSON:
+		return format, nil
+	case "":
+		return "", errors.New("format is required, use ?format=csv or ?format=ndjson")
+	default:
+		return "", fmt.Errorf("unsupported format %q, use csv or ndjson", format)
+	}
+}
+
+// ImportProducts stores the uploaded file and imports it in the background.
+// The response points to the import whose progress can be polled.
+func (h *ProductHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	format, err := importFormat(r)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	// Large files take longer to upload than the server timeouts allow
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	// The body is spooled to disk so the client does not wait for the import
+	file, err := os.CreateTemp("", "product-import-*")
+	if err != nil {
+		writeInternalError(w, r, "Failed to store import file", err)
+		return
+	}
+
+	if _, err := io.Copy(file, http.MaxBytesReader(w, r.Body, maxImportBytes)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Write(w, http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("import file exceeds %d bytes", maxImportBytes))
+			return
+		}
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Failed to read import file")
+		return
+	}
+
+	imp := models.ProductImport{
+		TenantID:  tenant.FromContext(r.Context()),
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    models.ImportStatusPending,
+		CreatedBy: actorFromRequest(r),
+		RequestID: middleware.RequestIDFrom(r.Context()),
+	}
+	if err := h.DB.WithContext(r.Context()).Create(&imp).Error; err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		writeInternalError(w, r, "Failed to create import", err)
+		return
+	}
+
+	h.imports.Add(1)
+	go func() {
+		defer h.imports.Done()
+		h.runImport(imp, file)
+	}()
+
+	w.Header().Set("Location", "/api/products/imports/"+imp.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(imp)
+}
+
+func (h *ProductHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var imp models.ProductImport
+	if err := h.DB.WithContext(r.Context()).Where("tenant_id = ? AND id = ?", tenant.FromContext(r.Context()), mux.Vars(r)["import_id"]).First(&imp).Error; err != nil {
+		writeLookupError(w, r, err, "import_not_found", "Import not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(imp)
+}
+
+// WaitImports blocks until running imports finish or ctx expires.
+func (h *ProductHandler) WaitImports(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.imports.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runImport processes an import file row by row, each row in its own
+// transaction, and saves progress as it goes.
+func (h *ProductHandler) runImport(imp models.ProductImport, file *os.File) {
+	ctx := context.Background()
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// A panic fails the import instead of leaving it running forever and
+	// taking the service down
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		slog.ErrorContext(ctx, "import: panicked", "import_id", imp.ID, "panic", v, "stack", string(debug.Stack()))
+		now := time.Now()
+		imp.FinishedAt = &now
+		imp.Status = models.ImportStatusFailed
+		imp.Error = "internal error"
+		h.saveImport(ctx, &imp)
+	}()
+
+	imp.Status = models.ImportStatusRunning
+	h.saveImport(ctx, &imp)
+
+	err := h.importFile(ctx, &imp, file)
+
+	now := time.Now()
+	imp.FinishedAt = &now
+	imp.Status = models.ImportStatusCompleted
+	if err != nil {
+		imp.Status = models.ImportStatusFailed
+		imp.Error = err.Error()
+	}
+	h.saveImport(ctx, &imp)
+
+	if !imp.DryRun && imp.Created+imp.Updated > 0 {
+		cache.InvalidateSearch(ctx, h.RedisClient, imp.TenantID)
+	}
+}
+
+func (h *ProductHandler) importFile(ctx context.Context, imp *models.ProductImport, file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read import file: %v", err)
+	}
+
+	next, err := newRowReader(imp.Format, file)
+	if err != nil {
+		return err
+	}
+
+	change := audit.Change{Tenant: imp.TenantID, Action: audit.ActionImport, Actor: imp.CreatedBy, RequestID: imp.RequestID}
+
+	for {
+		row, line, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		var rowErr error
+		if errors.As(err, &rowError{}) {
+			rowErr, err = err, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := models.ImportRow{Row: line, ExternalSKU: strings.TrimSpace(row.ExternalSKU)}
+		if rowErr == nil {
+			result.Action, result.Fields, rowErr = h.importProduct(ctx, row, imp.DryRun, change)
+		}
+
+		imp.Processed++
+		switch {
+		case rowErr != nil:
+			imp.Failed++
+			result.Action = ""
+			result.Error = rowErr.Error()
+			if len(imp.Errors) < maxImportReport {
+				imp.Errors = append(imp.Errors, result)
+			}
+		case result.Action == models.ImportActionCreated:
+			imp.Created++
+		case result.Action == models.ImportActionUpdated:
+			imp.Updated++
+		default:
+			imp.Unchanged++
+		}
+
+		// A dry run reports what would change
+		if rowErr == nil && imp.DryRun && result.Action != models.ImportActionUnchanged && len(imp.Changes) < maxImportReport {
+			imp.Changes = append(imp.Changes, result)
+		}
+
+		if imp.Processed%importProgressEvery == 0 {
+			h.saveImport(ctx, imp)
+		}
+	}
+}
+
+func (h *ProductHandler) saveImport(ctx context.Context, imp *models.ProductImport) {
+	if err := h.DB.WithContext(ctx).Save(imp).Error; err != nil {
+		slog.ErrorContext(ctx, "import: failed to save progress", "import_id", imp.ID, "error", err)
+	}
+}
+
+// importProduct validates a row and creates or updates the product of the
+// change's tenant with its external SKU. It returns the action and the changed fields.
+func (h *ProductHandler) importProduct(ctx context.Context, row importRow, dryRun bool, change audit.Change) (string, []string, error) {
+	sku := normalizeExternalSKU(&row.ExternalSKU)
+	if sku == nil {
+		return "", nil, errors.New("external_sku is required")
+	}
+	name := strings.TrimSpace(row.Name)
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+	if row.Price == nil {
+		return "", nil, errors.New("price is required")
+	}
+	if *row.Price < 0 {
+		return "", nil, errors.New("price must not be negative")
+	}
+
+	category, err := resolveCategory(h.DB.WithContext(ctx), change.Tenant, row.Category)
+	if err != nil {
+		if errors.Is(err, errCategoryRequired) || errors.Is(err, errCategoryNotFound) {
+			return "", nil, err
+		}
+		return "", nil, errors.New("failed to resolve category")
+	}
+
+	var action string
+	var fields []string
+
+	err = h.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Preload("Prices").Scopes(tenantProducts(change.Tenant)).Where("external_sku = ?", *sku)
+		if !dryRun {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+
+		var product models.Product
+		err := query.First(&product).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Omitted currency and attributes keep their current values
+		cur := h.DefaultCurrency
+		if exists {
+			cur = product.Currency
+		}
+		if row.Currency != "" {
+			cur = currency.Normalize(row.Currency)
+		}
+		if !currency.Valid(cur) {
+			return fmt.Errorf("%w: invalid currency %q", errImportRowInvalid, row.Currency)
+		}
+		if exists && cur != product.Currency {
+			for _, pp := range product.Prices {
+				if pp.Currency == cur {
+					return fmt.Errorf("%w: currency %s is also in the price list", errImportRowInvalid, cur)
+				}
+			}
+		}
+
+		attrs := row.Attributes
+		if attrs == nil && exists {
+			attrs = product.Attributes
+		}
+		if attrs == nil {
+			attrs = models.JSONMap{}
+		}
+		if err := h.validateAttributes(tx, change.Tenant, category, attrs); err != nil {
+			var invalid invalidAttributesError
+			if errors.As(err, &invalid) {
+				return fmt.Errorf("%w: %v", errImportRowInvalid, err)
+			}
+			return err
+		}
+
+		// New products start as drafts, existing ones follow the lifecycle
+		status := strings.ToLower(row.Status)
+		if !exists {
+			status, err = initialStatus(status)
+			if err != nil {
+				return fmt.Errorf("%w: %v", errImportRowInvalid, err)
+			}
+		} else if status == "" {
+			status = product.Status
+		} else if status != product.Status && !models.CanTransition(product.Status, status) {
+			return fmt.Errorf("%w: a %s product cannot become %q", errImportRowInvalid, product.Status, status)
+		}
+
+		if !exists {
+			action = models.ImportActionCreated
+			if dryRun {
+				return nil
+			}
+
+			product = models.Product{
+				TenantID:    change.Tenant,
+				ExternalSKU: sku,
+				Name:        name,
+				Description: row.Description,
+				Price:       *row.Price,
+				Currency:    cur,
+				Category:    category,
+				Status:      status,
+				Attributes:  attrs,
+				Version:     1,
+			}
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			if err := pricing.Record(tx, product.ID, nil, pricing.Snapshot(product), pricing.SourceImport, change.Actor, product.CreatedAt); err != nil {
+				return err
+			}
+			return audit.Record(tx, product.ID, change, nil, audit.Snapshot(product))
+		}
+
+		before := pricing.Snapshot(product)
+		updated := product
+		updated.Name = name
+		updated.Description = row.Description
+		updated.Price = *row.Price
+		updated.Currency = cur
+		updated.Category = category
+		updated.Status = status
+		updated.Attributes = attrs
+
+		fields = changedFields(product, updated)
+		if len(fields) == 0 {
+			action = models.ImportActionUnchanged
+			return nil
+		}
+
+		action = models.ImportActionUpdated
+		if dryRun {
+			return nil
+		}
+
+		if err := saveVersioned(tx, &updated, product.Version); err != nil {
+			return err
+		}
+		if err := pricing.Record(tx, updated.ID, before, pricing.Snapshot(updated), pricing.SourceImport, change.Actor, updated.UpdatedAt); err != nil {
+			return err
+		}
+		return audit.Record(tx, updated.ID, change, audit.Snapshot(product), audit.Snapshot(updated))
+	})
+	if errors.Is(err, errImportRowInvalid) {
+		return "", nil, errors.New(strings.TrimPrefix(err.Error(), errImportRowInvalid.Error()+": "))
+	}
+	if err != nil {
+		slog.Error("import: failed to save row", "sku", *sku, "request_id", change.RequestID, "error", err)
+		return "", nil, errors.New("failed to save product")
+	}
+
+	return action, fields, nil
+}
+
+// changedFields lists the imported fields that differ between two versions
+// of a product.
+func changedFields(before, after models.Product) []string {
+	var fields []string
+	if before.Name != after.Name {
+		fields = append(fields, "name")
+	}
+	if before.Description != after.Description {
+		fields = append(fields, "description")
+	}
+	if before.Price != after.Price {
+		fields = append(fields, "price")
+	}
+	if before.Currency != after.Currency {
+		fields = append(fields, "currency")
+	}
+	if before.Category != after.Category {
+		fields = append(fields, "category")
+	}
+	if before.Status != after.Status {
+		fields = append(fields, "status")
+	}
+	if !reflect.DeepEqual(before.Attributes, after.Attributes) {
+		fields = append(fields, "attributes")
+	}
+	return fields
+}
+
+func newRowReader(format string, file io.Reader) (rowReader, error) {
+	if format == importFormatCSV {
+		return newCSVReader(file)
+	}
+	return newNDJSONReader(file), nil
+}
+
+// newCSVReader reads rows by header name. external_sku, name and price are
+// required columns, attributes holds a JSON object.
+func newCSVReader(file io.Reader) (rowReader, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"external_sku", "name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+
+	return func() (importRow, int, error) {
+		record, err := reader.Read()
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return importRow{}, parseErr.StartLine, rowError{parseErr.Err}
+		}
+		if err != nil {
+			return importRow{}, 0, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := importRow{
+			ExternalSKU: value("external_sku"),
+			Name:        value("name"),
+			Description: value("description"),
+			Currency:    value("currency"),
+			Category:    value("category"),
+			Status:      value("status"),
+		}
+
+		if price := value("price"); price != "" {
+			p, err := strconv.ParseFloat(price, 64)
+			if err != nil {
+				return row, line, rowError{fmt.Errorf("invalid price %q", price)}
+			}
+			row.Price = &p
+		}
+
+		if attrs := value("attributes"); attrs != "" {
+			if err := json.Unmarshal([]byte(attrs), &row.Attributes); err != nil {
+				return row, line, rowError{errors.New("attributes must be a JSON object")}
+			}
+		}
+
+		return row, line, nil
+	}, nil
+}
+
+// newNDJSONReader reads one JSON object per line, skipping blank lines.
+func newNDJSONReader(file io.Reader) rowReader {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+	line := 0
+
+	return func() (importRow, int, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			var row importRow
+			if err := json.Unmarshal([]byte(text), &row); err != nil {
+				return row, line, rowError{fmt.Errorf("invalid JSON: %v", err)}
+			}
+			return row, line, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			return importRow{}, line + 1, fmt.Errorf("failed to read line %d: %v", line+1, err)
+		}
+		return importRow{}, line, io.EOF
+	}
+}
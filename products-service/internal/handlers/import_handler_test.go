@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+)
+
+func floatPtr(p float64) *float64 {
+	return &p
+}
+
+func TestImportFormat(t *testing.T) {
+	tests := []struct {
+		query       string
+		contentType string
+		want        string
+		wantErr     string
+	}{
+		{query: "format=csv", want: importFormatCSV},
+		{query: "format=NDJSON", want: importFormatNDJSON},
+		{query: "format=csv", contentType: "application/x-ndjson", want: importFormatCSV},
+		{contentType: "text/csv; charset=utf-8", want: importFormatCSV},
+		{contentType: "application/x-ndjson", want: importFormatNDJSON},
+		{contentType: "application/jsonl", want: importFormatNDJSON},
+		{wantErr: "format is required"},
+		{contentType: "application/json", wantErr: "format is required"},
+		{query: "format=xlsx", wantErr: `unsupported format "xlsx"`},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/products/imports?"+tt.query, nil)
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+
+		got, err := importFormat(req)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("importFormat(%q, %q) error = %v, want %q", tt.query, tt.contentType, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("importFormat(%q, %q) = %q, %v, want %q", tt.query, tt.contentType, got, err, tt.want)
+		}
+	}
+}
+
+func TestRowReader(t *testing.T) {
+	// result is one call of a rowReader: a row, or the error failing it
+	type result struct {
+		line   int
+		row    importRow
+		rowErr string
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []result
+		wantErr string
+	}{
+		{
+			name:   "csv",
+			format: importFormatCSV,
+			input: "external_sku,name,price,description,currency,category,status,attributes\n" +
+				"M-1,Mug,12.5,Blue mug,usd,kitchen,active,\"{\"\"color\"\":\"\"blue\"\"}\"\n" +
+				" M-2 , Cup ,3,,,,,\n",
+			want: []result{
+				{line: 2, row: importRow{ExternalSKU: "M-1", Name: "Mug", Price: floatPtr(12.5), Description: "Blue mug", Currency: "usd", Category: "kitchen", Status: "active", Attributes: models.JSONMap{"color": "blue"}}},
+				{line: 3, row: importRow{ExternalSKU: "M-2", Name: "Cup", Price: floatPtr(3)}},
+			},
+		},
+		{
+			name:   "csv header by name",
+			format: importFormatCSV,
+			input:  "\ufeffPrice, Name ,External_SKU\n4,Plate,P-1\n",
+			want: []result{
+				{line: 2, row: importRow{ExternalSKU: "P-1", Name: "Plate", Price: floatPtr(4)}},
+			},
+		},
+		{
+			name:   "csv short record",
+			format: importFormatCSV,
+			input:  "external_sku,name,price,category\nP-1,Plate\n",
+			want: []result{
+				{line: 2, row: importRow{ExternalSKU: "P-1", Name: "Plate"}},
+			},
+		},
+		{
+			name:   "csv row errors",
+			format: importFormatCSV,
+			input: "external_sku,name,price,attributes\n" +
+				"P-1,Plate,cheap,\n" +
+				"P-2,Bowl,2,[1]\n" +
+				"P-3,\"Cup,4,\n",
+			want: []result{
+				{line: 2, rowErr: `invalid price "cheap"`},
+				{line: 3, rowErr: "attributes must be a JSON object"},
+				{line: 4, rowErr: "quote"},
+			},
+		},
+		{
+			name:    "csv missing column",
+			format:  importFormatCSV,
+			input:   "external_sku,name\nP-1,Plate\n",
+			wantErr: "CSV header is missing the price column",
+		},
+		{
+			name:    "csv empty",
+			format:  importFormatCSV,
+			wantErr: "failed to read CSV header",
+		},
+		{
+			name:   "ndjson",
+			format: importFormatNDJSON,
+			input: `{"external_sku":"M-1","name":"Mug","price":12.5,"attributes":{"color":"blue"}}` + "\n" +
+				"\n" +
+				`  {"external_sku":"M-2","name":"Cup","price":0,"status":"draft"}  ` + "\n",
+			want: []result{
+				{line: 1, row: importRow{ExternalSKU: "M-1", Name: "Mug", Price: floatPtr(12.5), Attributes: models.JSONMap{"color": "blue"}}},
+				{line: 3, row: importRow{ExternalSKU: "M-2", Name: "Cup", Price: floatPtr(0), Status: "draft"}},
+			},
+		},
+		{
+			name:   "ndjson row errors",
+			format: importFormatNDJSON,
+			input: `{"external_sku":"M-1"` + "\n" +
+				`{"external_sku":"M-2","price":"12"}` + "\n" +
+				`{"external_sku":"M-3","name":"Plate"}`,
+			want: []result{
+				{line: 1, rowErr: "invalid JSON"},
+				{line: 2, rowErr: "invalid JSON"},
+				{line: 3, row: importRow{ExternalSKU: "M-3", Name: "Plate"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := newRowReader(tt.format, strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newRowReader() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newRowReader() error = %v", err)
+			}
+
+			for i, want := range tt.want {
+				row, line, err := next()
+				if line != want.line {
+					t.Errorf("row %d: line = %d, want %d", i, line, want.line)
+				}
+				if want.rowErr != "" {
+					if !errors.As(err, &rowError{}) || !strings.Contains(err.Error(), want.rowErr) {
+						t.Errorf("row %d: error = %v, want a row error with %q", i, err, want.rowErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("row %d: error = %v", i, err)
+				}
+				if !reflect.DeepEqual(row, want.row) {
+					t.Errorf("row %d = %+v, want %+v", i, row, want.row)
+				}
+			}
+
+			if _, _, err := next(); err != io.EOF {
+				t.Errorf("after the last row: error = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestNDJSONLineTooLong(t *testing.T) {
+	input := `{"name":"` + strings.Repeat("x", maxImportLine) + `"}` + "\n"
+	next, err := newRowReader(importFormatNDJSON, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, line, err := next()
+	if err == nil || errors.As(err, &rowError{}) {
+		t.Fatalf("error = %v, want an error aborting the import", err)
+	}
+	if line != 1 {
+		t.Errorf("line = %d, want 1", line)
+	}
+}
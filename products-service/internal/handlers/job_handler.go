@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/jobs"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// bulkProgressEvery is how many bulk items are processed between progress
+// updates of a job.
+const bulkProgressEvery = 50
+
+type JobHandler struct {
+	DB *gorm.DB
+}
+
+// bulkJobPayload is the input of an asynchronous bulk update.
+type bulkJobPayload struct {
+	Products []bulkItem `json:"products"`
+	Atomic   bool       `json:"atomic"`
+}
+
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, limit := getPaginationParams(r)
+
+	query := h.DB.WithContext(r.Context()).Model(&models.Job{}).Omit("result").Where("tenant_id = ?", tenant.FromContext(r.Context()))
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType := r.URL.Query().Get("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var list []models.Job
+	if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&list).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch jobs", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"page":  page,
+		"limit": limit,
+		"count": len(list),
+		"jobs":  list,
+	})
+}
+
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var job models.Job
+	if err := h.DB.WithContext(r.Context()).Where("tenant_id = ? AND id = ?", tenant.FromContext(r.Context()), mux.Vars(r)["job_id"]).First(&job).Error; err != nil {
+		writeLookupError(w, r, err, "job_not_found", "Job not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	job, err := jobs.Cancel(h.DB.WithContext(r.Context()), tenant.FromContext(r.Context()), mux.Vars(r)["job_id"])
+	if errors.Is(err, jobs.ErrNotFound) {
+		problem.Write(w, http.StatusNotFound, "job_not_found", "Job not found")
+		return
+	}
+	if errors.Is(err, jobs.ErrFinished) {
+		problem.Write(w, http.StatusConflict, "job_finished", "Job already finished")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to cancel job", err)
+		return
+	}
+
+	// A running job stops shortly after
+	if job.Status == models.JobStatusRunning {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// RunBulkUpdateJob executes an asynchronous bulk update. Items that already
+// have a result from an interrupted run are skipped.
+func (h *ProductHandler) RunBulkUpdateJob(ctx context.Context, run *jobs.Run) error {
+	var payload bulkJobPayload
+	if err := json.Unmarshal(run.Job.Payload, &payload); err != nil {
+		return errors.New("invalid bulk update payload")
+	}
+	items := payload.Products
+	if len(items) == 0 {
+		return nil
+	}
+
+	results := make([]bulkItemResult, len(items))
+	if len(run.Job.Result) > 0 {
+		json.Unmarshal(run.Job.Result, &results)
+	}
+	if len(results) != len(items) {
+		results = make([]bulkItemResult, len(items))
+	}
+	change := audit.Change{Tenant: run.Job.TenantID, Action: audit.ActionBulk, Actor: run.Job.CreatedBy, RequestID: run.Job.RequestID}
+
+	// An atomic update is all or nothing, a saved result means it already ran
+	if payload.Atomic {
+		if ctx.Err() == nil && results[0].Status == "" {
+			var err error
+			if results, err = h.bulkUpdateAtomic(ctx, items, change); err != nil {
+				slog.ErrorContext(ctx, "bulk update: failed to commit", "job_id", run.Job.ID, "error", err)
+				return errors.New("failed to commit bulk update")
+			}
+		}
+		return h.finishBulkJob(run, items, results)
+	}
+
+	var pending []int
+	for i, result := range results {
+		if result.Status == "" {
+			pending = append(pending, i)
+		}
+	}
+
+	var mu sync.Mutex
+	done := 0
+	h.bulkUpdateEach(ctx, items, pending, change, func(i int, result bulkItemResult) {
+		// Items skipped by a shutdown stay pending and are resumed, those of
+		// a cancelled job are marked by finishBulkJob
+		if result.Status == bulkStatusSkipped {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		results[i] = result
+		done++
+		if done%bulkProgressEvery == 0 {
+			processed, succeeded, failed := countBulkResults(results)
+			if err := run.Progress(processed, succeeded, failed, results); err != nil {
+				slog.ErrorContext(ctx, "bulk update: failed to save progress", "job_id", run.Job.ID, "error", err)
+			}
+		}
+	})
+
+	return h.finishBulkJob(run, items, results)
+}
+
+// finishBulkJob saves the final results. Items left over by a cancellation
+// are reported as skipped, after a shutdown they are resumed instead.
+func (h *ProductHandler) finishBulkJob(run *jobs.Run, items []bulkItem, results []bulkItemResult) error {
+	if run.Cancelled() {
+		for i := range results {
+			if results[i].Status == "" {
+				results[i] = bulkItemResult{ID: items[i].ID, Status: bulkStatusSkipped}
+			}
+		}
+	}
+
+	processed, succeeded, failed := countBulkResults(results)
+	if succeeded > 0 {
+		cache.InvalidateSearch(context.Background(), h.RedisClient, run.Job.TenantID)
+	}
+	return run.Progress(processed, succeeded, failed, results)
+}
+
+func countBulkResults(results []bulkItemResult) (processed, succeeded, failed int) {
+	for _, result := range results {
+		switch result.Status {
+		case "", bulkStatusSkipped:
+		case bulkStatusUpdated:
+			processed++
+			succeeded++
+		default:
+			processed++
+			failed++
+		}
+	}
+	return processed, succeeded, failed
+}
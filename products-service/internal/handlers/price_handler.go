@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/auth"
+	"github.com/MosaabBleik/products-service/internal/currency"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// actorFromRequest identifies who made a change: the authenticated
+// principal, or the X-Actor header when authentication is disabled.
+func actorFromRequest(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Subject
+	}
+	if actor := strings.TrimSpace(r.Header.Get("X-Actor")); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+func (h *ProductHandler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	page, limit := getPaginationParams(r)
+
+	cur, err := getCurrencyParam(r)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if err := h.DB.WithContext(r.Context()).Select("id").Scopes(tenantProducts(tenant.FromContext(r.Context()))).Where("id = ?", id).First(&models.Product{}).Error; err != nil {
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
+		return
+	}
+
+	query := h.DB.WithContext(r.Context()).Where("product_id = ?", id)
+	if cur != "" {
+		query = query.Where("currency = ?", cur)
+	}
+
+	var history []models.PriceChange
+	offset := (page - 1) * limit
+	if err := query.Order("effective_at DESC, created_at DESC").Limit(limit).Offset(offset).Find(&history).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch price history", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"product_id": id,
+		"page":       page,
+		"limit":      limit,
+		"count":      len(history),
+		"history":    history,
+	})
+}
+
+func (h *ProductHandler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	var req struct {
+		Currency    string    `json:"currency"`
+		Price       float64   `json:"price"`
+		EffectiveAt time.Time `json:"effective_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	if req.Price < 0 {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "price must not be negative")
+		return
+	}
+	if !req.EffectiveAt.After(time.Now()) {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "effective_at must be in the future")
+		return
+	}
+
+	var product models.Product
+	if err := h.DB.WithContext(r.Context()).Scopes(tenantProducts(tenant.FromContext(r.Context()))).Where("id = ?", id).First(&product).Error; err != nil {
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
+		return
+	}
+
+	// Without a currency the change applies to the default price
+	cur := product.Currency
+	if req.Currency != "" {
+		cur = currency.Normalize(req.Currency)
+	}
+	if !currency.Valid(cur) {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid currency %q", req.Currency))
+		return
+	}
+
+	scheduled := models.ScheduledPrice{
+		ProductID:   product.ID,
+		Currency:    cur,
+		Price:       req.Price,
+		EffectiveAt: req.EffectiveAt.UTC(),
+		Status:      models.ScheduleStatusPending,
+		CreatedBy:   actorFromRequest(r),
+	}
+
+	if err := h.DB.WithContext(r.Context()).Create(&scheduled).Error; err != nil {
+		writeInternalError(w, r, "Failed to schedule price change", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (h *ProductHandler) ListScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	query := h.DB.WithContext(r.Context()).Scopes(tenantProductRows(tenant.FromContext(r.Context()))).Where("product_id = ?", id)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var scheduled []models.ScheduledPrice
+	if err := query.Order("effective_at ASC").Find(&scheduled).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch scheduled prices", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"product_id": id,
+		"count":      len(scheduled),
+		"scheduled":  scheduled,
+	})
+}
+
+func (h *ProductHandler) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+
+	var scheduled models.ScheduledPrice
+	err := h.DB.WithContext(r.Context()).Scopes(tenantProductRows(tenant.FromContext(r.Context()))).
+		Where("id = ? AND product_id = ?", vars["schedule_id"], vars["id"]).
+		First(&scheduled).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, "scheduled_price_change_not_found", "Scheduled price change not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to fetch scheduled price change", err)
+		return
+	}
+
+	// Only pending changes can be cancelled, the scheduler may race us
+	result := h.DB.WithContext(r.Context()).Model(&scheduled).
+		Where("status = ?", models.ScheduleStatusPending).
+		Update("status", models.ScheduleStatusCancelled)
+	if result.Error != nil {
+		writeInternalError(w, r, "Failed to cancel scheduled price change", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		problem.Write(w, http.StatusConflict, "schedule_not_pending", "Scheduled price change is no longer pending")
+		return
+	}
+
+	json.NewEncoder(w).Encode(scheduled)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/MosaabBleik/products-service/internal/currency"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// pricedProduct is a product row carrying its price in the requested currency.
+type pricedProduct struct {
+	models.Product
+	EffectivePrice *float64
+}
+
+// getCurrencyParam reads the optional currency= query parameter.
+func getCurrencyParam(r *http.Request) (string, error) {
+	code := currency.Normalize(r.URL.Query().Get("currency"))
+	if code == "" {
+		return "", nil
+	}
+	if !currency.Valid(code) {
+		return "", fmt.Errorf("invalid currency %q", code)
+	}
+	return code, nil
+}
+
+// validatePricing checks the price and currency of a product and returns its
+// normalized price list. Creates and updates share it so they accept the
+// same products.
+func validatePricing(price float64, cur string, prices []models.ProductPrice) ([]models.ProductPrice, error) {
+	if price < 0 {
+		return nil, errors.New("price must not be negative")
+	}
+	if !currency.Valid(cur) {
+		return nil, fmt.Errorf("invalid currency %q", cur)
+	}
+	return normalizePrices(prices, cur)
+}
+
+// normalizePrices validates a price list. Entries for the default currency
+// and duplicates are rejected.
+func normalizePrices(prices []models.ProductPrice, defaultCurrency string) ([]models.ProductPrice, error) {
+	seen := make(map[string]bool, len(prices))
+	normalized := make([]models.ProductPrice, 0, len(prices))
+
+	for _, p := range prices {
+		code := currency.Normalize(p.Currency)
+		if !currency.Valid(code) {
+			return nil, fmt.Errorf("invalid currency %q", p.Currency)
+		}
+		if code == defaultCurrency {
+			return nil, fmt.Errorf("%s is the default currency, use price instead", code)
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("duplicate price for %s", code)
+		}
+		if p.Amount < 0 {
+			return nil, fmt.Errorf("price for %s must not be negative", code)
+		}
+		seen[code] = true
+
+		normalized = append(normalized, models.ProductPrice{Currency: code, Amount: p.Amount})
+	}
+
+	return normalized, nil
+}
+
+// priceIn resolves the price of p in cur: the default price, then the
+// explicit price list, then the exchange-rate table.
+func (h *ProductHandler) priceIn(p models.Product, cur string) (float64, bool) {
+	if p.Currency == cur {
+		return p.Price, true
+	}
+	for _, pp := range p.Prices {
+		if pp.Currency == cur {
+			return pp.Amount, true
+		}
+	}
+	return h.Rates.Convert(p.Price, p.Currency, cur)
+}
+
+// priceExpr is the SQL counterpart of priceIn. It expects product_prices to
+// be joined as pp for cur.
+func (h *ProductHandler) priceExpr(cur string) (string, []any) {
+	sql := "CASE WHEN products.currency = ? THEN products.price WHEN pp.amount IS NOT NULL THEN pp.amount"
+	vars := []any{cur}
+
+	// Sorted so the statement text is stable for the prepared statement cache
+	codes := make([]string, 0, len(h.Rates))
+	for code := range h.Rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, from := range codes {
+		factor, ok := h.Rates.Factor(from, cur)
+		if !ok || from == cur {
+			continue
+		}
+		sql += " WHEN products.currency = ? THEN ROUND((products.price * ?)::numeric, 2)::float8"
+		vars = append(vars, from, factor)
+	}
+
+	return sql + " END", vars
+}
+
+// pricedProducts returns a query over the products of a tenant exposing an
+// effective_price column. Without a currency it is the product's own price.
+func (h *ProductHandler) pricedProducts(tenantID, cur string) *gorm.DB {
+	if cur == "" {
+		inner := h.DB.Model(&models.Product{}).
+			Scopes(tenantProducts(tenantID)).
+			Select("products.*, products.price AS effective_price")
+		return h.DB.Table("(?) AS products", inner)
+	}
+
+	expr, vars := h.priceExpr(cur)
+	inner := h.DB.Model(&models.Product{}).
+		Scopes(tenantProducts(tenantID)).
+		Select("products.*, "+expr+" AS effective_price", vars...).
+		Joins("LEFT JOIN product_prices pp ON pp.product_id = products.id AND pp.currency = ?", cur)
+
+	return h.DB.Table("(?) AS products", inner)
+}
+
+func toProductResponse(p pricedProduct, cur string) ProductResponse {
+	if cur == "" {
+		cur = p.Currency
+	}
+	return ProductResponse{
+		ID:         p.ID,
+		Name:       p.Name,
+		Price:      p.EffectivePrice,
+		Currency:   cur,
+		Category:   p.Category,
+		Status:     p.Status,
+		Attributes: p.Attributes,
+	}
+}
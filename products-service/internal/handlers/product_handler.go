@@ -3,16 +3,27 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/currency"
+	"github.com/MosaabBleik/products-service/internal/jobs"
+	"github.com/MosaabBleik/products-service/internal/metrics"
 	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/pricing"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -21,10 +32,38 @@ const (
 )
 
 type ProductResponse struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Category string  `json:"category"`
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Price      *float64       `json:"price"`
+	Currency   string         `json:"currency"`
+	Category   string         `json:"category"`
+	Status     string         `json:"status"`
+	Attributes models.JSONMap `json:"attributes,omitempty"`
+}
+
+type productRequest struct {
+	ExternalSKU *string               `json:"external_sku"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Price       float64               `json:"price"`
+	Currency    string                `json:"currency"`
+	Prices      []models.ProductPrice `json:"prices"`
+	Category    string                `json:"category"`
+	Status      string                `json:"status"`
+	Attributes  models.JSONMap        `json:"attributes"`
+}
+
+// productPatch holds the fields of a partial update, nil fields are kept.
+type productPatch struct {
+	ExternalSKU *string                `json:"external_sku"`
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	Price       *float64               `json:"price"`
+	Currency    *string                `json:"currency"`
+	Prices      *[]models.ProductPrice `json:"prices"`
+	Category    *string                `json:"category"`
+	Status      *string                `json:"status"`
+	Attributes  *models.JSONMap        `json:"attributes"`
 }
 
 func getPaginationParams(r *http.Request) (page int, limit int) {
@@ -57,9 +96,34 @@ func getPaginationParams(r *http.Request) (page int, limit int) {
 	return page, limit
 }
 
+// tenantProducts limits a query to the products of a tenant.
+func tenantProducts(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("products.tenant_id = ?", tenantID)
+	}
+}
+
+// tenantProductRows limits a query over rows with a product_id, such as
+// variants and prices, to the products of a tenant.
+func tenantProductRows(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		products := db.Session(&gorm.Session{NewDB: true}).
+			Model(&models.Product{}).
+			Select("id").
+			Where("tenant_id = ?", tenantID)
+		return db.Where("product_id IN (?)", products)
+	}
+}
+
 type ProductHandler struct {
-	DB          *gorm.DB
-	RedisClient *redis.Client
+	DB              *gorm.DB
+	RedisClient     *redis.Client
+	Rates           currency.Rates
+	DefaultCurrency string
+	CacheControl    CacheControl
+	BulkWorkers     int
+
+	imports sync.WaitGroup
 }
 
 func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
@@ -73,32 +137,51 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		limit = 10
 	}
 
-	var products []models.Product
+	cur, err := getCurrencyParam(r)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	// Every status is listed unless status= narrows it down
+	query := h.pricedProducts(tenant.FromContext(r.Context()), cur).WithContext(r.Context())
+	if status := strings.ToLower(r.URL.Query().Get("status")); status != "" {
+		if !models.ValidProductStatus(status) {
+			problem.Write(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid status %q", status))
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var products []pricedProduct
 	offset := (page - 1) * limit
-	if err := h.DB.Limit(limit).Offset(offset).Find(&products).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to fetch products",
-		})
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&products).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch products", err)
 		return
 	}
 
 	response := make([]ProductResponse, 0)
 	for _, p := range products {
-		response = append(response, ProductResponse{
-			ID:       p.ID,
-			Name:     p.Name,
-			Price:    p.Price,
-			Category: p.Category,
-		})
+		response = append(response, toProductResponse(p, cur))
 	}
 
-	json.NewEncoder(w).Encode(map[string]any{
+	result := map[string]any{
 		"page":     page,
 		"limit":    limit,
 		"count":    len(response),
 		"products": response,
-	})
+	}
+	if cur != "" {
+		result["currency"] = cur
+	}
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		writeInternalError(w, r, "Failed to encode response", err)
+		return
+	}
+
+	writeCacheable(w, r, jsonBytes, h.CacheControl.List)
 }
 
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
@@ -106,52 +189,128 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	id := vars["id"]
+	tenantID := tenant.FromContext(r.Context())
 
+	cur, err := getCurrencyParam(r)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	// Revalidation only needs the version, so a 304 skips the preloads
 	var product models.Product
-	if err := h.DB.Where("id = ?", id).First(&product).Error; err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Product not found",
-		})
+	if err := h.DB.WithContext(r.Context()).Select("id, version, updated_at").Scopes(tenantProducts(tenantID)).Where("id = ?", id).First(&product).Error; err != nil {
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
+		return
+	}
+
+	etag := productETag(product)
+	if cur != "" {
+		etag = currencyETag(product, cur)
+	}
+	lastModified := product.UpdatedAt
+	setValidators(w, etag, lastModified, h.CacheControl.Product)
+	if notModified(r, etag, lastModified) {
+		writeNotModified(w)
 		return
 	}
 
+	if err := h.DB.WithContext(r.Context()).Preload("Prices").Preload("Variants").Scopes(tenantProducts(tenantID)).Where("id = ?", id).First(&product).Error; err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Last-Modified")
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
+		return
+	}
+
+	if cur != "" {
+		price, ok := h.priceIn(product, cur)
+		if !ok {
+			w.Header().Del("ETag")
+			w.Header().Del("Last-Modified")
+			problem.Write(w, http.StatusUnprocessableEntity, "price_unavailable", fmt.Sprintf("Price not available in %s", cur))
+			return
+		}
+		product.Price = price
+		product.Currency = cur
+	}
+
 	json.NewEncoder(w).Encode(product)
 }
 
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var req struct {
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		Price       float64 `json:"price"`
-		Category    string  `json:"category"`
-	}
+	var req productRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	cur := h.DefaultCurrency
+	if req.Currency != "" {
+		cur = currency.Normalize(req.Currency)
+	}
+	prices, err := validatePricing(req.Price, cur, req.Prices)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	category, err := resolveCategory(h.DB.WithContext(r.Context()), tenant.FromContext(r.Context()), req.Category)
+	if err != nil {
+		writeCategoryResolveError(w, r, err)
+		return
+	}
+
+	if req.Attributes == nil {
+		req.Attributes = models.JSONMap{}
+	}
+	if err := h.validateAttributes(h.DB.WithContext(r.Context()), tenant.FromContext(r.Context()), category, req.Attributes); err != nil {
+		writeAttributesError(w, r, err)
+		return
+	}
+
+	status, err := initialStatus(req.Status)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
 		return
 	}
 
 	product := models.Product{
+		TenantID:    tenant.FromContext(r.Context()),
+		ExternalSKU: normalizeExternalSKU(req.ExternalSKU),
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
-		Category:    req.Category,
+		Currency:    cur,
+		Prices:      prices,
+		Category:    category,
+		Status:      status,
+		Attributes:  req.Attributes,
+		Version:     1,
 	}
 
-	if err := h.DB.Create(&product).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to create product",
-		})
+	err = h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		change := changeFromRequest(r, audit.ActionCreate)
+		if err := pricing.Record(tx, product.ID, nil, pricing.Snapshot(product), pricing.SourceCreate, change.Actor, product.CreatedAt); err != nil {
+			return err
+		}
+		return audit.Record(tx, product.ID, change, nil, audit.Snapshot(product))
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		problem.Write(w, http.StatusConflict, "external_sku_taken", "external_sku already exists")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to create product", err)
 		return
 	}
 
+	w.Header().Set("ETag", productETag(product))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(product)
 }
@@ -159,81 +318,341 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	ctx := context.Background()
+	var req productRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	// PUT replaces the product, external SKU, currency, price list and
+	// attributes are kept when omitted
+	patch := productPatch{
+		ExternalSKU: req.ExternalSKU,
+		Name:        &req.Name,
+		Description: &req.Description,
+		Price:       &req.Price,
+		Category:    &req.Category,
+	}
+	if req.Currency != "" {
+		patch.Currency = &req.Currency
+	}
+	if req.Prices != nil {
+		patch.Prices = &req.Prices
+	}
+	if req.Status != "" {
+		patch.Status = &req.Status
+	}
+	if req.Attributes != nil {
+		patch.Attributes = &req.Attributes
+	}
+
+	h.applyPatch(w, r, patch, audit.ActionUpdate)
+}
+
+func (h *ProductHandler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var patch productPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	h.applyPatch(w, r, patch, audit.ActionPatch)
+}
+
+// applyPatch validates and writes a change to a product, guarded by the
+// version in If-Match. The change is audited as action.
+func (h *ProductHandler) applyPatch(w http.ResponseWriter, r *http.Request, patch productPatch, action string) {
+	// Invalidation still runs if the client goes away
+	ctx := context.WithoutCancel(r.Context())
 
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	var req struct {
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		Price       float64 `json:"price"`
-		Category    string  `json:"category"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+	expected, ok := requireIfMatch(w, r)
+	if !ok {
 		return
 	}
 
+	change := changeFromRequest(r, action)
+
 	var product models.Product
-	if err := h.DB.Where("id = ?", id).First(&product).Error; err != nil {
-		http.Error(w, "Product not found", http.StatusNotFound)
+	if err := h.DB.WithContext(r.Context()).Preload("Prices").Scopes(tenantProducts(change.Tenant)).Where("id = ?", id).First(&product).Error; err != nil {
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
 		return
 	}
 
-	product.Name = req.Name
-	product.Description = req.Description
-	product.Price = req.Price
-	product.Category = req.Category
+	if expected == anyVersion {
+		expected = product.Version
+	}
+	if product.Version != expected {
+		writeVersionConflict(w, product)
+		return
+	}
 
-	if err := h.DB.Save(&product).Error; err != nil {
-		http.Error(w, "Failed to update product", http.StatusInternalServerError)
+	err := h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		return h.patchProduct(tx, &product, patch, expected, pricing.SourceUpdate, change)
+	})
+	var invalid invalidProductError
+	if errors.As(err, &invalid) {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if errors.Is(err, errVersionConflict) {
+		problem.Write(w, http.StatusPreconditionFailed, "version_conflict", "Product was modified by another request")
+		return
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		problem.Write(w, http.StatusConflict, "external_sku_taken", "external_sku already exists")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to update product", err)
 		return
 	}
 
-	h.RedisClient.FlushDB(ctx)
+	cache.InvalidateSearch(ctx, h.RedisClient, change.Tenant)
 
+	w.Header().Set("ETag", productETag(product))
 	json.NewEncoder(w).Encode(product)
 }
 
+// invalidProductError is a product change rejected by validation.
+type invalidProductError struct {
+	error
+}
+
+// patchProduct merges patch into product, validates it and writes it within
+// tx if the stored version is still expected. Price changes are recorded
+// with source, changed fields are audited as change.
+func (h *ProductHandler) patchProduct(tx *gorm.DB, product *models.Product, patch productPatch, expected int, source string, change audit.Change) error {
+	before := pricing.Snapshot(*product)
+	audited := audit.Snapshot(*product)
+
+	if patch.ExternalSKU != nil {
+		product.ExternalSKU = normalizeExternalSKU(patch.ExternalSKU)
+	}
+	if patch.Name != nil {
+		product.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		product.Description = *patch.Description
+	}
+	// A status sent back unchanged is accepted, so a fetched product can be
+	// saved as is
+	if patch.Status != nil && strings.ToLower(strings.TrimSpace(*patch.Status)) != product.Status {
+		return invalidProductError{errors.New("status is changed with POST /api/products/{id}/status")}
+	}
+	if patch.Price != nil {
+		product.Price = *patch.Price
+	}
+	if patch.Currency != nil {
+		product.Currency = currency.Normalize(*patch.Currency)
+	}
+	requested := product.Prices
+	if patch.Prices != nil {
+		requested = *patch.Prices
+	}
+	prices, err := validatePricing(product.Price, product.Currency, requested)
+	if err != nil {
+		return invalidProductError{err}
+	}
+
+	if patch.Category != nil {
+		category, err := resolveCategory(tx, product.TenantID, *patch.Category)
+		if errors.Is(err, errCategoryRequired) || errors.Is(err, errCategoryNotFound) {
+			return invalidProductError{err}
+		}
+		if err != nil {
+			return err
+		}
+		product.Category = category
+	}
+
+	// Attributes are checked against the (possibly new) category
+	if patch.Attributes != nil {
+		product.Attributes = *patch.Attributes
+	}
+	if product.Attributes == nil {
+		product.Attributes = models.JSONMap{}
+	}
+	if err := h.validateAttributes(tx, product.TenantID, product.Category, product.Attributes); err != nil {
+		var invalid invalidAttributesError
+		if errors.As(err, &invalid) {
+			return invalidProductError{err}
+		}
+		return err
+	}
+
+	if err := saveVersioned(tx, product, expected); err != nil {
+		return err
+	}
+	if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductPrice{}).Error; err != nil {
+		return err
+	}
+	for i := range prices {
+		prices[i].ProductID = product.ID
+	}
+	if len(prices) > 0 {
+		if err := tx.Create(&prices).Error; err != nil {
+			return err
+		}
+	}
+	product.Prices = prices
+
+	if err := pricing.Record(tx, product.ID, before, pricing.Snapshot(*product), source, change.Actor, product.UpdatedAt); err != nil {
+		return err
+	}
+	return audit.Record(tx, product.ID, change, audited, audit.Snapshot(*product))
+}
+
 func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	ctx := context.Background()
+	// Invalidation still runs if the client goes away
+	ctx := context.WithoutCancel(r.Context())
 
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := h.DB.Delete(&models.Product{}, "id = ?", id).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to delete product",
-		})
+	expected, ok := requireIfMatch(w, r)
+	if !ok {
 		return
 	}
 
-	h.RedisClient.FlushDB(ctx)
+	// The product is loaded first so the audit entry keeps its last state
+	change := changeFromRequest(r, audit.ActionDelete)
+	var product models.Product
+	err := h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Prices").
+			Scopes(tenantProducts(change.Tenant)).
+			Where("id = ?", id).
+			First(&product).Error
+		if err != nil {
+			return err
+		}
+		if expected != anyVersion && product.Version != expected {
+			return errVersionConflict
+		}
+
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, product.ID, change, audit.Snapshot(product), nil)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, "product_not_found", "Product not found")
+		return
+	}
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, product)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to delete product", err)
+		return
+	}
+
+	cache.InvalidateSearch(ctx, h.RedisClient, change.Tenant)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Product deleted successfully"))
 }
 
+// searchFilters are the product filters shared by search and export.
+type searchFilters struct {
+	Tenant      string
+	Query       string
+	Category    string
+	MinPrice    float64
+	MaxPrice    float64
+	Currency    string
+	Status      string
+	AttrFilters []attrFilter
+}
+
+func parseSearchFilters(r *http.Request) (searchFilters, error) {
+	cur, err := getCurrencyParam(r)
+	if err != nil {
+		return searchFilters{}, err
+	}
+
+	attrFilters, err := parseAttrFilters(r.URL.RawQuery)
+	if err != nil {
+		return searchFilters{}, err
+	}
+
+	// Only active products are listed unless status= names another status,
+	// or all
+	status := strings.ToLower(r.URL.Query().Get("status"))
+	switch {
+	case status == "":
+		status = models.ProductStatusActive
+	case status == "all":
+		status = ""
+	case !models.ValidProductStatus(status):
+		return searchFilters{}, fmt.Errorf("invalid status %q, use draft, active, discontinued, archived or all", status)
+	}
+
+	minPrice, _ := strconv.ParseFloat(r.URL.Query().Get("min_price"), 64)
+	maxPrice, _ := strconv.ParseFloat(r.URL.Query().Get("max_price"), 64)
+
+	return searchFilters{
+		Tenant:      tenant.FromContext(r.Context()),
+		Query:       r.URL.Query().Get("q"),
+		Category:    r.URL.Query().Get("category"),
+		MinPrice:    minPrice,
+		MaxPrice:    maxPrice,
+		Currency:    cur,
+		Status:      status,
+		AttrFilters: attrFilters,
+	}, nil
+}
+
+// searchQuery builds the filtered product query. Prices are filtered in the
+// requested currency.
+func (h *ProductHandler) searchQuery(f searchFilters) *gorm.DB {
+	query := h.pricedProducts(f.Tenant, f.Currency)
+	if f.Currency != "" {
+		query = query.Where("effective_price IS NOT NULL")
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.Query != "" {
+		query = query.Where("LOWER(name) LIKE LOWER(?)", "%"+f.Query+"%")
+	}
+	if f.Category != "" {
+		// Includes products of every descendant category
+		query = query.Scopes(categoryScope(h.DB, f.Tenant, f.Category))
+	}
+	if f.MinPrice > 0 {
+		query = query.Where("effective_price >= ?", f.MinPrice)
+	}
+	if f.MaxPrice > 0 {
+		query = query.Where("effective_price <= ?", f.MaxPrice)
+	}
+	if len(f.AttrFilters) > 0 {
+		query = query.Scopes(attrFilterScope(f.AttrFilters))
+	}
+	return query
+}
+
 func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	ctx := context.Background()
+	ctx := r.Context()
 
-	q := r.URL.Query().Get("q")
-	category := r.URL.Query().Get("category")
-	minPriceStr := r.URL.Query().Get("min_price")
-	maxPriceStr := r.URL.Query().Get("max_price")
 	sort := r.URL.Query().Get("sort")
 
-	minPrice, _ := strconv.ParseFloat(minPriceStr, 64)
-	maxPrice, _ := strconv.ParseFloat(maxPriceStr, 64)
+	filters, err := parseSearchFilters(r)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	cur := filters.Currency
 
 	page, limit := getPaginationParams(r)
 
@@ -246,38 +665,28 @@ func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// --- Build Redis cache key ---
-	cacheKey := fmt.Sprintf(
-		"products:search:q=%s:cat=%s:min=%.2f:max=%.2f:sort=%s:page=%d:limit=%d",
-		q, category, minPrice, maxPrice, sort, page, limit,
+	cacheKey := cache.SearchKeyPrefix(filters.Tenant) + fmt.Sprintf(
+		"q=%s:cat=%s:min=%.2f:max=%.2f:cur=%s:status=%s:attr=%v:sort=%s:page=%d:limit=%d",
+		filters.Query, filters.Category, filters.MinPrice, filters.MaxPrice, cur, filters.Status, filters.AttrFilters, sort, page, limit,
 	)
 
 	// --- Try to get cached result ---
 	cached, err := h.RedisClient.Get(ctx, cacheKey).Result()
 	if err == nil && cached != "" {
-		w.Write([]byte(cached)) // cache hit
+		metrics.SearchCache.WithLabelValues("hit").Inc()
+		writeCacheable(w, r, []byte(cached), h.CacheControl.Search) // cache hit
 		return
 	}
+	metrics.SearchCache.WithLabelValues("miss").Inc()
 
-	query := h.DB.Model(&models.Product{})
-	if q != "" {
-		query = query.Where("LOWER(name) LIKE LOWER(?)", "%"+q+"%")
-	}
-	if category != "" {
-		query = query.Where("category = ?", category)
-	}
-	if minPrice > 0 {
-		query = query.Where("price >= ?", minPrice)
-	}
-	if maxPrice > 0 {
-		query = query.Where("price <= ?", maxPrice)
-	}
+	query := h.searchQuery(filters).WithContext(r.Context())
 
 	// Sorting (default newest)
 	switch strings.ToLower(sort) {
 	case "price":
-		query = query.Order("price ASC")
+		query = query.Order("effective_price ASC")
 	case "price_desc":
-		query = query.Order("price DESC")
+		query = query.Order("effective_price DESC")
 	case "name":
 		query = query.Order("name ASC")
 	case "name_desc":
@@ -291,23 +700,15 @@ func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 	query = query.Limit(limit).Offset(offset)
 
 	// Fetch Products
-	var products []models.Product
+	var products []pricedProduct
 	if err := query.Find(&products).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "failed to fetch products",
-		})
+		writeInternalError(w, r, "Failed to fetch products", err)
 		return
 	}
 
 	response := make([]ProductResponse, 0)
 	for _, p := range products {
-		response = append(response, ProductResponse{
-			ID:       p.ID,
-			Name:     p.Name,
-			Price:    p.Price,
-			Category: p.Category,
-		})
+		response = append(response, toProductResponse(p, cur))
 	}
 
 	result := map[string]any{
@@ -316,109 +717,302 @@ func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 		"count":    len(response),
 		"products": response,
 	}
+	if cur != "" {
+		result["currency"] = cur
+	}
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "failed to encode response",
-		})
+		writeInternalError(w, r, "Failed to encode response", err)
 		return
 	}
 
 	_ = h.RedisClient.Set(ctx, cacheKey, jsonBytes, 5*time.Minute).Err()
 
-	w.Write(jsonBytes)
+	writeCacheable(w, r, jsonBytes, h.CacheControl.Search)
 }
 
+// defaultBulkWorkers is the size of the bulk update worker pool unless
+// configured.
+const defaultBulkWorkers = 10
+
+const (
+	bulkStatusUpdated    = "updated"
+	bulkStatusNotFound   = "not_found"
+	bulkStatusInvalid    = "invalid"
+	bulkStatusConflict   = "conflict"
+	bulkStatusError      = "error"
+	bulkStatusRolledBack = "rolled_back"
+	bulkStatusSkipped    = "skipped"
+)
+
+// bulkItem is one change of a bulk update. Version is optional; when given,
+// the item fails if the product has moved on.
+type bulkItem struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	productPatch
+}
+
+type bulkItemResult struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// empty reports whether the item changes no field.
+func (item bulkItem) empty() bool {
+	p := item.productPatch
+	return p.ExternalSKU == nil && p.Name == nil && p.Description == nil && p.Price == nil &&
+		p.Currency == nil && p.Prices == nil && p.Category == nil && p.Attributes == nil
+}
+
+// updateItem locks and patches one product within tx.
+func (h *ProductHandler) updateItem(tx *gorm.DB, item bulkItem, change audit.Change) bulkItemResult {
+	result := bulkItemResult{ID: item.ID}
+
+	if item.ID == "" {
+		result.Status = bulkStatusInvalid
+		result.Error = "id is required"
+		return result
+	}
+	if item.empty() {
+		result.Status = bulkStatusInvalid
+		result.Error = "no fields to update"
+		return result
+	}
+
+	var product models.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Prices").
+		Scopes(tenantProducts(change.Tenant)).
+		Where("id = ?", item.ID).
+		First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		result.Status = bulkStatusNotFound
+		result.Error = "Product not found"
+		return result
+	}
+
+	if err == nil {
+		if item.Version != 0 && item.Version != product.Version {
+			err = errVersionConflict
+		} else {
+			err = h.patchProduct(tx, &product, item.productPatch, product.Version, pricing.SourceBulk, change)
+		}
+	}
+
+	var invalid invalidProductError
+	switch {
+	case err == nil:
+		result.Status = bulkStatusUpdated
+		result.Version = product.Version
+	case errors.As(err, &invalid):
+		result.Status = bulkStatusInvalid
+		result.Error = err.Error()
+	case errors.Is(err, errVersionConflict):
+		result.Status = bulkStatusConflict
+		result.Version = product.Version
+		result.Error = "Product was modified by another request"
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		result.Status = bulkStatusInvalid
+		result.Error = "external_sku already exists"
+	default:
+		result.Status = bulkStatusError
+		result.Error = "Failed to update product"
+	}
+	return result
+}
+
+// BulkUpdate applies a list of product changes. Each item succeeds or fails
+// on its own, unless atomic=true applies all of them in one transaction.
 func (h *ProductHandler) BulkUpdate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	type BulkRequest struct {
-		Products []models.Product `json:"products"`
+		Products []bulkItem `json:"products"`
 	}
 
 	var req BulkRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request body",
-		})
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
 		return
 	}
 
 	if len(req.Products) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "no products to update",
-		})
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "no products to update")
 		return
 	}
 
-	const workerCount = 10
-	jobs := make(chan models.Product, len(req.Products))
-	results := make(chan bool, len(req.Products))
+	atomic, _ := strconv.ParseBool(r.URL.Query().Get("atomic"))
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	change := changeFromRequest(r, audit.ActionBulk)
+
+	// Large updates run as a job that survives the client going away
+	if async {
+		payload := bulkJobPayload{Products: req.Products, Atomic: atomic}
+		job, err := jobs.Submit(h.DB.WithContext(r.Context()), models.Job{
+			TenantID:  change.Tenant,
+			Type:      models.JobTypeBulkUpdate,
+			Total:     len(req.Products),
+			CreatedBy: change.Actor,
+			RequestID: change.RequestID,
+		}, payload)
+		if err != nil {
+			writeInternalError(w, r, "Failed to submit bulk update", err)
+			return
+		}
 
-	// Worker function
-	for range workerCount {
-		go func() {
-			for p := range jobs {
-				// Only update the fields provided (e.g. price)
-				err := h.DB.Model(&models.Product{}).
-					Where("id = ?", p.ID).
-					Updates(map[string]any{
-						"price": p.Price,
-					}).Error
-				results <- err == nil
-			}
-		}()
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
 	}
 
-	// Send jobs
-	for _, p := range req.Products {
-		jobs <- p
+	var results []bulkItemResult
+	if atomic {
+		var err error
+		results, err = h.bulkUpdateAtomic(r.Context(), req.Products, change)
+		if err != nil {
+			writeInternalError(w, r, "Failed to commit bulk update", err)
+			return
+		}
+	} else {
+		results = make([]bulkItemResult, len(req.Products))
+		pending := make([]int, len(req.Products))
+		for i := range pending {
+			pending[i] = i
+		}
+		h.bulkUpdateEach(r.Context(), req.Products, pending, change, func(i int, result bulkItemResult) {
+			results[i] = result
+		})
 	}
-	close(jobs)
 
-	// Collect results
-	succeeded, failed := 0, 0
-	for i := 0; i < len(req.Products); i++ {
-		if <-results {
+	succeeded, skipped := 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case bulkStatusUpdated:
 			succeeded++
-		} else {
-			failed++
+		case bulkStatusSkipped:
+			skipped++
 		}
 	}
 
-	// Response summary
-	resp := map[string]int{
-		"total":     len(req.Products),
-		"succeeded": succeeded,
-		"failed":    failed,
+	if succeeded > 0 {
+		cache.InvalidateSearch(r.Context(), h.RedisClient, change.Tenant)
 	}
 
-	json.NewEncoder(w).Encode(resp)
+	// An atomic update that rolled back changed nothing
+	if atomic && succeeded == 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"total":     len(results),
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded - skipped,
+		"skipped":   skipped,
+		"atomic":    atomic,
+		"results":   results,
+	})
 }
 
-func (h *ProductHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// bulkUpdateEach updates the pending items, each in its own transaction, on
+// a worker pool and reports every result. Once ctx is done the remaining
+// items are reported as skipped.
+func (h *ProductHandler) bulkUpdateEach(ctx context.Context, items []bulkItem, pending []int, change audit.Change, report func(i int, result bulkItemResult)) {
+	workerCount := h.BulkWorkers
+	if workerCount <= 0 {
+		workerCount = defaultBulkWorkers
+	}
+	queue := make(chan int, len(pending))
 
-	ctx := context.Background()
-	dbStatus := "ok"
-	redisStatus := "ok"
+	var wg sync.WaitGroup
 
-	if err := h.DB.Exec("SELECT 1").Error; err != nil {
-		dbStatus = "error"
+	// Worker function
+	for range workerCount {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					report(i, bulkItemResult{ID: items[i].ID, Status: bulkStatusSkipped})
+					continue
+				}
+				metrics.BulkUpdateWorkersBusy.Inc()
+				start := time.Now()
+				var result bulkItemResult
+				h.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+					result = h.updateItem(tx, items[i], change)
+					if result.Status != bulkStatusUpdated {
+						return errors.New(result.Status)
+					}
+					return nil
+				})
+				metrics.BulkUpdateItemDuration.Observe(time.Since(start).Seconds())
+				metrics.BulkUpdateItems.WithLabelValues(result.Status).Inc()
+				metrics.BulkUpdateWorkersBusy.Dec()
+				report(i, result)
+			}
+		}()
 	}
 
-	if err := h.RedisClient.Ping(ctx).Err(); err != nil {
-		redisStatus = "error"
+	// Send jobs
+	for _, i := range pending {
+		queue <- i
 	}
+	close(queue)
+
+	wg.Wait()
+}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":   "ok",
-		"database": dbStatus,
-		"redis":    redisStatus,
+// bulkUpdateAtomic applies all items in one transaction and stops at the
+// first failure, rolling back the items before it. An error means the
+// transaction itself failed, not one of the items.
+func (h *ProductHandler) bulkUpdateAtomic(ctx context.Context, items []bulkItem, change audit.Change) ([]bulkItemResult, error) {
+	results := make([]bulkItemResult, len(items))
+
+	failed := -1
+	err := h.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, item := range items {
+			results[i] = h.updateItem(tx, item, change)
+			if results[i].Status != bulkStatusUpdated {
+				failed = i
+				return errors.New(results[i].Status)
+			}
+		}
+		return nil
 	})
+	if err == nil {
+		countBulkItems(results)
+		return results, nil
+	}
+	if failed == -1 {
+		return nil, err
+	}
+	// An item that failed because ctx ended says nothing about the items
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	for i := range results {
+		switch {
+		case i < failed:
+			results[i] = bulkItemResult{ID: items[i].ID, Status: bulkStatusRolledBack}
+		case i > failed:
+			results[i] = bulkItemResult{ID: items[i].ID, Status: bulkStatusSkipped}
+		}
+	}
+	countBulkItems(results)
+	return results, nil
+}
+
+// countBulkItems adds the outcome of an atomic bulk update to the item
+// metrics, once it is known whether it committed.
+func countBulkItems(results []bulkItemResult) {
+	for _, result := range results {
+		metrics.BulkUpdateItems.WithLabelValues(result.Status).Inc()
+	}
 }
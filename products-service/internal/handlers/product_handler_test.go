@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateProductValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantDetail string
+	}{
+		{name: "invalid JSON", body: `{"name":`, wantDetail: "Invalid request body"},
+		{name: "negative price", body: `{"name":"Mug","price":-5}`, wantDetail: "price must not be negative"},
+		{name: "invalid currency", body: `{"name":"Mug","price":5,"currency":"dollars"}`, wantDetail: "invalid currency"},
+		{name: "price list repeats the default", body: `{"name":"Mug","price":5,"prices":[{"currency":"sar","amount":5}]}`, wantDetail: "SAR is the default currency"},
+		{name: "negative list price", body: `{"name":"Mug","price":5,"prices":[{"currency":"USD","amount":-1}]}`, wantDetail: "price for USD must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validation fails before the database is used
+			h := &ProductHandler{DefaultCurrency: "SAR"}
+			req := httptest.NewRequest(http.MethodPost, "/api/products", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			h.CreateProduct(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantDetail) {
+				t.Errorf("body = %s, want it to contain %q", rec.Body, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestValidatePricing(t *testing.T) {
+	tests := []struct {
+		price   float64
+		cur     string
+		wantErr string
+	}{
+		{price: 0, cur: "USD"},
+		{price: 12.5, cur: "SAR"},
+		{price: -0.01, cur: "USD", wantErr: "price must not be negative"},
+		{price: 5, cur: "US", wantErr: `invalid currency "US"`},
+	}
+
+	for _, tt := range tests {
+		_, err := validatePricing(tt.price, tt.cur, nil)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validatePricing(%v, %q) error = %v", tt.price, tt.cur, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validatePricing(%v, %q) error = %v, want %q", tt.price, tt.cur, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// initialStatus validates the status of a new product. Products start as
+// drafts unless created active.
+func initialStatus(status string) (string, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case "":
+		return models.ProductStatusDraft, nil
+	case models.ProductStatusDraft, models.ProductStatusActive:
+		return status, nil
+	}
+	return "", fmt.Errorf("a new product must be %s or %s", models.ProductStatusDraft, models.ProductStatusActive)
+}
+
+// errInvalidTransition is a status change the product lifecycle does not allow.
+var errInvalidTransition = errors.New("invalid status transition")
+
+// SetStatus moves a product to the next publication status, guarded by the
+// version in If-Match.
+func (h *ProductHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	if !models.ValidProductStatus(status) {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("invalid status %q, use draft, active, discontinued or archived", req.Status))
+		return
+	}
+
+	expected, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	change := changeFromRequest(r, audit.ActionStatus)
+	var product models.Product
+	err := h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Prices").
+			Scopes(tenantProducts(change.Tenant)).
+			Where("id = ?", id).
+			First(&product).Error
+		if err != nil {
+			return err
+		}
+
+		if expected == anyVersion {
+			expected = product.Version
+		}
+		if product.Version != expected {
+			return errVersionConflict
+		}
+		if !models.CanTransition(product.Status, status) {
+			return errInvalidTransition
+		}
+
+		before := audit.Snapshot(product)
+		product.Status = status
+		product.Version++
+		err = tx.Model(&product).Select("status", "version", "updated_at").Updates(&product).Error
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, product.ID, change, before, audit.Snapshot(product))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(w, http.StatusNotFound, "product_not_found", "Product not found")
+		return
+	}
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, product)
+		return
+	}
+	if errors.Is(err, errInvalidTransition) {
+		problem.Write(w, http.StatusConflict, "invalid_status_transition", fmt.Sprintf("a %s product cannot become %s", product.Status, status))
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, "Failed to update product status", err)
+		return
+	}
+
+	cache.InvalidateSearch(r.Context(), h.RedisClient, change.Tenant)
+
+	w.Header().Set("ETag", productETag(product))
+	json.NewEncoder(w).Encode(product)
+}
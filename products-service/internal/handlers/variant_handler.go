@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type variantRequest struct {
+	SKU           string         `json:"sku"`
+	Options       models.JSONMap `json:"options"`
+	PriceOverride *float64       `json:"price_override"`
+	Barcode       *string        `json:"barcode"`
+}
+
+// VariantResponse is a variant with its resolved price, used by other
+// services to resolve a variant ID to its product.
+type VariantResponse struct {
+	models.Variant
+	ProductName   string  `json:"product_name"`
+	ProductStatus string  `json:"product_status"`
+	Price         float64 `json:"price"`
+	Currency      string  `json:"currency"`
+}
+
+// validate normalizes the request and checks SKU, options and price.
+func (req *variantRequest) validate() error {
+	req.SKU = strings.ToUpper(strings.TrimSpace(req.SKU))
+	if req.SKU == "" {
+		return errors.New("sku is required")
+	}
+
+	if req.Options == nil {
+		req.Options = models.JSONMap{}
+	}
+	for name, value := range req.Options {
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf("option %q must be a string, number or boolean", name)
+		}
+	}
+
+	if req.PriceOverride != nil && *req.PriceOverride < 0 {
+		return errors.New("price_override must not be negative")
+	}
+
+	if req.Barcode != nil {
+		barcode := strings.TrimSpace(*req.Barcode)
+		if barcode == "" {
+			req.Barcode = nil
+		} else {
+			req.Barcode = &barcode
+		}
+	}
+
+	return nil
+}
+
+func (h *ProductHandler) ListVariants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	if err := h.DB.WithContext(r.Context()).Select("id").Scopes(tenantProducts(tenant.FromContext(r.Context()))).Where("id = ?", id).First(&models.Product{}).Error; err != nil {
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
+		return
+	}
+
+	var variants []models.Variant
+	if err := h.DB.WithContext(r.Context()).Where("product_id = ?", id).Order("sku ASC").Find(&variants).Error; err != nil {
+		writeInternalError(w, r, "Failed to fetch variants", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"product_id": id,
+		"count":      len(variants),
+		"variants":   variants,
+	})
+}
+
+func (h *ProductHandler) GetVariant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	variantID := mux.Vars(r)["variant_id"]
+
+	var variant models.Variant
+	err := h.DB.WithContext(r.Context()).Preload("Product").
+		Scopes(tenantProductRows(tenant.FromContext(r.Context()))).
+		Where("id = ?", variantID).
+		First(&variant).Error
+	if err != nil {
+		writeLookupError(w, r, err, "variant_not_found", "Variant not found")
+		return
+	}
+
+	// Variant changes bump the product version, so it validates both
+	etag := productETag(*variant.Product)
+	setValidators(w, etag, variant.Product.UpdatedAt, h.CacheControl.Product)
+	if notModified(r, etag, variant.Product.UpdatedAt) {
+		writeNotModified(w)
+		return
+	}
+
+	// Variants without an override are sold at the product price
+	price := variant.Product.Price
+	if variant.PriceOverride != nil {
+		price = *variant.PriceOverride
+	}
+
+	json.NewEncoder(w).Encode(VariantResponse{
+		Variant:       variant,
+		ProductName:   variant.Product.Name,
+		ProductStatus: variant.Product.Status,
+		Price:         price,
+		Currency:      variant.Product.Currency,
+	})
+}
+
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+
+	var req variantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if err := h.DB.WithContext(r.Context()).Select("id").Scopes(tenantProducts(tenant.FromContext(r.Context()))).Where("id = ?", id).First(&models.Product{}).Error; err != nil {
+		writeLookupError(w, r, err, "product_not_found", "Product not found")
+		return
+	}
+
+	variant := models.Variant{
+		TenantID:      tenant.FromContext(r.Context()),
+		ProductID:     id,
+		SKU:           req.SKU,
+		Options:       req.Options,
+		PriceOverride: req.PriceOverride,
+		Barcode:       req.Barcode,
+	}
+
+	err := h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		return touchProduct(tx, id)
+	})
+	if err != nil {
+		writeVariantSaveError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+
+	var req variantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		problem.Write(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	var variant models.Variant
+	err := h.DB.WithContext(r.Context()).Scopes(tenantProductRows(tenant.FromContext(r.Context()))).
+		Where("id = ? AND product_id = ?", vars["variant_id"], vars["id"]).
+		First(&variant).Error
+	if err != nil {
+		writeLookupError(w, r, err, "variant_not_found", "Variant not found")
+		return
+	}
+
+	variant.SKU = req.SKU
+	variant.Options = req.Options
+	variant.PriceOverride = req.PriceOverride
+	variant.Barcode = req.Barcode
+
+	err = h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&variant).Error; err != nil {
+			return err
+		}
+		return touchProduct(tx, variant.ProductID)
+	})
+	if err != nil {
+		writeVariantSaveError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+
+	var deleted int64
+	err := h.DB.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(tenantProductRows(tenant.FromContext(r.Context()))).
+			Where("id = ? AND product_id = ?", vars["variant_id"], vars["id"]).
+			Delete(&models.Variant{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return touchProduct(tx, vars["id"])
+	})
+	if err != nil {
+		writeInternalError(w, r, "Failed to delete variant", err)
+		return
+	}
+	if deleted == 0 {
+		problem.Write(w, http.StatusNotFound, "variant_not_found", "Variant not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Variant deleted successfully"))
+}
+
+func writeVariantSaveError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		problem.Write(w, http.StatusConflict, "sku_taken", "SKU or barcode already exists")
+		return
+	}
+
+	writeInternalError(w, r, "Failed to save variant", err)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"gorm.io/gorm"
+)
+
+// anyVersion is the expected version for "If-Match: *".
+const anyVersion = -1
+
+var errVersionConflict = errors.New("version conflict")
+
+// productETag is the entity tag of a product, derived from its version.
+func productETag(p models.Product) string {
+	return fmt.Sprintf(`"%d"`, p.Version)
+}
+
+// currencyETag tags the representation of a product priced in another
+// currency, e.g. "3-USD".
+func currencyETag(p models.Product, cur string) string {
+	return fmt.Sprintf(`"%d-%s"`, p.Version, cur)
+}
+
+// parseETagVersion extracts the version from an entity tag, ignoring a
+// currency suffix. Weak tags never match for If-Match.
+func parseETagVersion(tag string) (int, bool) {
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return anyVersion, true
+	}
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	value, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	version, err := strconv.Atoi(value)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// requireIfMatch reads the expected version from If-Match. It writes 428 when
+// the header is missing and 412 when it cannot match any version.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		problem.Write(w, http.StatusPreconditionRequired, "if_match_required", "If-Match header is required, use the ETag from GET /api/products/{id}")
+		return 0, false
+	}
+
+	version, ok := parseETagVersion(strings.Split(header, ",")[0])
+	if !ok {
+		problem.Write(w, http.StatusPreconditionFailed, "version_conflict", "If-Match does not match the current product version")
+		return 0, false
+	}
+
+	return version, true
+}
+
+func writeVersionConflict(w http.ResponseWriter, current models.Product) {
+	w.Header().Set("ETag", productETag(current))
+	problem.New(http.StatusPreconditionFailed, "version_conflict", "Product was modified by another request").
+		With("current_version", current.Version).
+		Write(w)
+}
+
+// touchProduct bumps the version of a product whose representation changed
+// through a related resource, such as a variant.
+func touchProduct(tx *gorm.DB, productID string) error {
+	return tx.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Update("version", gorm.Expr("version + 1")).Error
+}
+
+// saveVersioned writes the mutable columns of product if its stored version
+// is still expected, and bumps the version.
+func saveVersioned(tx *gorm.DB, product *models.Product, expected int) error {
+	product.Version = expected + 1
+
+	result := tx.Model(product).
+		Where("version = ?", expected).
+		Select("external_sku", "name", "description", "price", "currency", "category", "status", "attributes", "version", "updated_at").
+		Updates(product)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		product.Version = expected
+		return errVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+)
+
+func TestProductETag(t *testing.T) {
+	p := models.Product{Version: 3}
+	if got, want := productETag(p), `"3"`; got != want {
+		t.Errorf("productETag() = %s, want %s", got, want)
+	}
+	if got, want := currencyETag(p, "USD"), `"3-USD"`; got != want {
+		t.Errorf("currencyETag() = %s, want %s", got, want)
+	}
+}
+
+func TestParseETagVersion(t *testing.T) {
+	tests := []struct {
+		tag         string
+		wantVersion int
+		wantOK      bool
+	}{
+		{`"3"`, 3, true},
+		{` "12" `, 12, true},
+		{`"3-USD"`, 3, true},
+		{`*`, anyVersion, true},
+		{`W/"3"`, 0, false},
+		{`3`, 0, false},
+		{`"`, 0, false},
+		{`""`, 0, false},
+		{`"0"`, 0, false},
+		{`"-1"`, 0, false},
+		{`"abc"`, 0, false},
+		{``, 0, false},
+	}
+
+	for _, tt := range tests {
+		version, ok := parseETagVersion(tt.tag)
+		if version != tt.wantVersion || ok != tt.wantOK {
+			t.Errorf("parseETagVersion(%q) = %d, %v, want %d, %v", tt.tag, version, ok, tt.wantVersion, tt.wantOK)
+		}
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantVersion int
+		wantOK      bool
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "version", header: `"4"`, wantVersion: 4, wantOK: true},
+		{name: "currency tag", header: `"4-EUR"`, wantVersion: 4, wantOK: true},
+		{name: "any", header: `*`, wantVersion: anyVersion, wantOK: true},
+		{name: "first of a list", header: `"4", "5"`, wantVersion: 4, wantOK: true},
+		{name: "missing", wantStatus: http.StatusPreconditionRequired, wantCode: "if_match_required"},
+		{name: "weak", header: `W/"4"`, wantStatus: http.StatusPreconditionFailed, wantCode: "version_conflict"},
+		{name: "malformed", header: `four`, wantStatus: http.StatusPreconditionFailed, wantCode: "version_conflict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/products/1", nil)
+			if tt.header != "" {
+				req.Header.Set("If-Match", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			version, ok := requireIfMatch(rec, req)
+			if version != tt.wantVersion || ok != tt.wantOK {
+				t.Fatalf("requireIfMatch() = %d, %v, want %d, %v", version, ok, tt.wantVersion, tt.wantOK)
+			}
+			if ok {
+				if rec.Body.Len() > 0 {
+					t.Errorf("requireIfMatch() wrote %q on success", rec.Body)
+				}
+				return
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrFinished = errors.New("job already finished")
+)
+
+// staleAfter is how many poll intervals a running job may go without a
+// heartbeat before another worker takes it over.
+const staleAfter = 10
+
+// Func executes a job. It should stop when ctx is cancelled and report
+// progress through run, so an interrupted job can resume.
+type Func func(ctx context.Context, run *Run) error
+
+// Runner executes queued jobs with a fixed number of workers. Jobs are claimed
+// with SKIP LOCKED so several replicas can share the queue.
+type Runner struct {
+	DB           *gorm.DB
+	Workers      int
+	PollInterval time.Duration
+
+	funcs map[string]Func
+}
+
+// Register sets the function executing jobs of jobType.
+func (r *Runner) Register(jobType string, fn Func) {
+	if r.funcs == nil {
+		r.funcs = make(map[string]Func)
+	}
+	r.funcs[jobType] = fn
+}
+
+// Submit queues job with payload. The caller sets its type, total, tenant,
+// actor and the request that asked for it.
+func Submit(db *gorm.DB, job models.Job, payload any) (models.Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job.Status = models.JobStatusQueued
+	job.Payload = b
+	return job, db.Create(&job).Error
+}
+
+// Cancel cancels a queued job of a tenant right away, a running job stops at
+// its next heartbeat.
+func Cancel(db *gorm.DB, tenantID, id string) (models.Job, error) {
+	var job models.Job
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tenant_id = ? AND id = ?", tenantID, id).First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		switch job.Status {
+		case models.JobStatusQueued:
+			now := time.Now()
+			job.Status = models.JobStatusCancelled
+			job.FinishedAt = &now
+		case models.JobStatusRunning:
+			job.CancelRequested = true
+		default:
+			return ErrFinished
+		}
+		return tx.Select("status", "cancel_requested", "finished_at", "updated_at").Save(&job).Error
+	})
+	return job, err
+}
+
+// Run starts the workers and blocks until ctx is cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range r.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before waiting for the next tick
+		for {
+			job, err := r.claim(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "jobs: failed to claim job", "error", err)
+				break
+			}
+			if job == nil || ctx.Err() != nil {
+				break
+			}
+			r.execute(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim takes the oldest queued job, or a running job whose worker stopped
+// sending heartbeats.
+func (r *Runner) claim(ctx context.Context) (*models.Job, error) {
+	var job models.Job
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stale := time.Now().Add(-staleAfter * r.PollInterval)
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)", models.JobStatusQueued, models.JobStatusRunning, stale).
+			Order("created_at ASC").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = models.JobStatusRunning
+		if job.StartedAt == nil {
+			job.StartedAt = &now
+		}
+		return tx.Select("status", "started_at", "updated_at").Save(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *Runner) execute(ctx context.Context, job *models.Job) {
+	if job.CancelRequested {
+		r.finish(job, models.JobStatusCancelled, "")
+		return
+	}
+
+	fn, ok := r.funcs[job.Type]
+	if !ok {
+		r.finish(job, models.JobStatusFailed, fmt.Sprintf("unknown job type %q", job.Type))
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	run := &Run{Job: job, db: r.DB}
+	done := make(chan struct{})
+	go func() {
+		r.heartbeat(jobCtx, run, cancel)
+		close(done)
+	}()
+
+	err := fn(jobCtx, run)
+	cancel()
+	<-done
+
+	switch {
+	case run.Cancelled():
+		r.finish(job, models.JobStatusCancelled, "")
+	case ctx.Err() != nil:
+		// Shutting down, the job is queued again and resumes where it stopped
+		r.requeue(job)
+	case err != nil:
+		r.finish(job, models.JobStatusFailed, err.Error())
+	default:
+		r.finish(job, models.JobStatusCompleted, "")
+	}
+}
+
+// heartbeat keeps the job claimed and cancels it when a cancel is requested.
+func (r *Runner) heartbeat(ctx context.Context, run *Run, cancel context.CancelFunc) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var requested bool
+		err := r.DB.Model(&models.Job{}).Where("id = ?", run.Job.ID).
+			Update("updated_at", time.Now()).Error
+		if err == nil {
+			err = r.DB.Model(&models.Job{}).Where("id = ?", run.Job.ID).
+				Pluck("cancel_requested", &requested).Error
+		}
+		if err != nil {
+			slog.Error("jobs: heartbeat failed", "job_id", run.Job.ID, "error", err)
+			continue
+		}
+		if requested {
+			run.cancelled.Store(true)
+			cancel()
+			return
+		}
+	}
+}
+
+func (r *Runner) requeue(job *models.Job) {
+	err := r.DB.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, models.JobStatusRunning).
+		Update("status", models.JobStatusQueued).Error
+	if err != nil {
+		slog.Error("jobs: failed to requeue job", "job_id", job.ID, "error", err)
+	}
+}
+
+func (r *Runner) finish(job *models.Job, status, message string) {
+	now := time.Now()
+	err := r.DB.Model(&models.Job{}).Where("id = ?", job.ID).Updates(map[string]any{
+		"status":      status,
+		"error":       message,
+		"finished_at": now,
+	}).Error
+	if err != nil {
+		slog.Error("jobs: failed to finish job", "job_id", job.ID, "error", err)
+	}
+}
+
+// Run is a job being executed.
+type Run struct {
+	Job *models.Job
+
+	db        *gorm.DB
+	mu        sync.Mutex
+	cancelled atomic.Bool
+}
+
+// Cancelled reports whether the job was stopped by a cancel request rather
+// than a shutdown.
+func (r *Run) Cancelled() bool {
+	return r.cancelled.Load()
+}
+
+// Progress saves the counters and result of the job so far.
+func (r *Run) Progress(processed, succeeded, failed int, result any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	r.Job.Processed = processed
+	r.Job.Succeeded = succeeded
+	r.Job.Failed = failed
+	r.Job.Result = b
+
+	return r.db.Model(&models.Job{}).Where("id = ?", r.Job.ID).Updates(map[string]any{
+		"processed": processed,
+		"succeeded": succeeded,
+		"failed":    failed,
+		"result":    r.Job.Result,
+	}).Error
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	// HTTPRequests counts requests by method, route template and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes request latency by method, route template and
+	// status code
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// SearchCache counts search cache lookups by result, hit or miss
+	SearchCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "products_search_cache_requests_total",
+		Help: "Search cache lookups by result (hit or miss).",
+	}, []string{"result"})
+
+	// BulkUpdateItems counts bulk update items by outcome
+	BulkUpdateItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "products_bulk_update_items_total",
+		Help: "Bulk update items processed by status.",
+	}, []string{"status"})
+
+	// BulkUpdateItemDuration observes how long a worker takes per item
+	BulkUpdateItemDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "products_bulk_update_item_duration_seconds",
+		Help:    "Time a bulk update worker spends on one item.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// BulkUpdateWorkersBusy is the number of bulk update workers working on
+	// an item
+	BulkUpdateWorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "products_bulk_update_workers_busy",
+		Help: "Bulk update workers currently updating an item.",
+	})
+)
+
+// RegisterDB exposes the connection pool stats of db as go_sql_* metrics
+// labeled with name.
+func RegisterDB(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return prometheus.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/problem"
+)
+
+// MaxBytes refuses request bodies larger than n bytes: right away when the
+// Content-Length says so, otherwise when the handler reads past the limit.
+func MaxBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				problem.Write(w, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("Request body must be at most %d bytes", n))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireJSON refuses request bodies that are not JSON with a 415. Requests
+// without a body pass.
+func RequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+				problem.Write(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/json")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/auth"
+	"github.com/MosaabBleik/products-service/internal/problem"
+	"github.com/MosaabBleik/products-service/internal/tenant"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// maxIdempotencyKey bounds the length of a client key
+	maxIdempotencyKey = 255
+	// maxIdempotentBody bounds the request body kept in memory to fingerprint it
+	maxIdempotentBody = 10 << 20
+	// idempotencyLockTTL is how long a key stays reserved by a request in
+	// flight, so a crashed request does not block retries for the whole window
+	idempotencyLockTTL = time.Minute
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// Idempotency-Key. A zero Status means the request is still in flight.
+type IdempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyStore keeps idempotency records for a retention window.
+type IdempotencyStore interface {
+	// Reserve stores rec under key unless the key is taken, in which case the
+	// existing record is returned with reserved set to false.
+	Reserve(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) (existing IdempotencyRecord, reserved bool, err error)
+	// Complete replaces the reservation of key with the final response.
+	Complete(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error
+	// Release drops a reservation so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key and body. Reusing a key with a different request
+// returns 422, retrying while the first request is in flight returns 409.
+// Server errors are not stored, so they can be retried.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKey {
+				problem.Write(w, http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					problem.Write(w, http.StatusRequestEntityTooLarge, "request_too_large", "Request body too large")
+					return
+				}
+				problem.Write(w, http.StatusBadRequest, "invalid_request", "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Keys are scoped to the endpoint, the tenant and the caller
+			storeKey := tenant.FromContext(r.Context()) + " " + r.Method + " " + r.URL.Path + " " + key
+			if principal, ok := auth.FromContext(r.Context()); ok {
+				storeKey = principal.Subject + " " + storeKey
+			}
+			fingerprint := requestFingerprint(r, body)
+
+			ctx := r.Context()
+			existing, reserved, err := store.Reserve(ctx, storeKey, IdempotencyRecord{Fingerprint: fingerprint}, idempotencyLockTTL)
+			if err != nil {
+				slog.ErrorContext(ctx, "idempotency: failed to reserve key", "error", err)
+				problem.Write(w, http.StatusServiceUnavailable, "idempotency_unavailable", "Idempotency store unavailable")
+				return
+			}
+
+			if !reserved {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					problem.Write(w, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used with a different request")
+				case existing.Status == 0:
+					problem.Write(w, http.StatusConflict, "request_in_progress", "A request with this Idempotency-Key is still in progress")
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					// The handler panicked, let the client retry
+					store.Release(context.Background(), storeKey)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+			completed = true
+
+			if rec.status >= http.StatusInternalServerError {
+				if err := store.Release(context.Background(), storeKey); err != nil {
+					slog.ErrorContext(ctx, "idempotency: failed to release key", "error", err)
+				}
+				return
+			}
+
+			final := IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Status:      rec.status,
+				Header:      storedHeader(rec.Header()),
+				Body:        rec.body.Bytes(),
+			}
+			if err := store.Complete(context.Background(), storeKey, final, ttl); err != nil {
+				slog.ErrorContext(ctx, "idempotency: failed to store response", "error", err)
+			}
+		})
+	}
+}
+
+// requestFingerprint hashes what makes two requests the same.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// perRequestHeaders describe the request that produced a response rather
+// than the response, so a replay keeps those of the current request.
+var perRequestHeaders = []string{RequestIDHeader, "Date", "Traceparent", "Tracestate"}
+
+// storedHeader returns the headers of a response worth replaying.
+func storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, name := range perRequestHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
+
+func replay(w http.ResponseWriter, rec IdempotencyRecord) {
+	// Records stored before per-request headers were dropped may hold them
+	for name, values := range storedHeader(rec.Header) {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// recorder passes a response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
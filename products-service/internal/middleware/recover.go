@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/MosaabBleik/products-service/internal/problem"
+)
+
+// Recover turns a panic in a handler into a 500, logged with its stack trace,
+// instead of a dropped connection. http.ErrAbortHandler is let through, as
+// it is how a handler aborts a response on purpose.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			slog.ErrorContext(r.Context(), "Handler panicked", "panic", v, "stack", string(debug.Stack()))
+			if !rec.wroteHeader {
+				problem.Write(w, http.StatusInternalServerError, problem.Code(http.StatusInternalServerError), "Internal server error")
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// timeoutWriteGrace is the time left to write the answer of a request once
+// its context is canceled.
+const timeoutWriteGrace = 10 * time.Second
+
+// Timeout cancels the context of a request after d, which stops the queries
+// and calls made with it. The handler still answers, see
+// handlers.writeInternalError. The write deadline of the connection is moved
+// to match, so the answer of a route bounded beyond the server write timeout
+// is not lost.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + timeoutWriteGrace))
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
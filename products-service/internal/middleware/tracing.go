@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Tracing starts a server span for every request, continuing the trace of
+// an incoming traceparent header. Spans are named after the route template,
+// metrics scrapes and probes are not traced.
+func Tracing(router *mux.Router, service string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, service,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + routeTemplate(router, r)
+			}),
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				switch r.URL.Path {
+				case "/metrics", "/livez", "/readyz":
+					return false
+				}
+				return true
+			}),
+		)
+	}
+}
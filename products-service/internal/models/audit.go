@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditEntry records one change of a product. Entries have no foreign key,
+// so the trail of a deleted product is kept.
+type AuditEntry struct {
+	ID        string       `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  string       `json:"tenant_id" gorm:"not null;default:'default'"`
+	ProductID string       `json:"product_id" gorm:"type:uuid;not null;index:idx_product_audit_product,priority:1"`
+	Action    string       `json:"action" gorm:"not null"`
+	Actor     string       `json:"actor" gorm:"not null"`
+	RequestID string       `json:"request_id,omitempty"`
+	Changes   AuditChanges `json:"changes" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt time.Time    `json:"created_at" gorm:"autoCreateTime;index:idx_product_audit_product,priority:2"`
+}
+
+func (AuditEntry) TableName() string {
+	return "product_audit"
+}
+
+// FieldChange is the value of a field before and after a change. A nil value
+// means the field was not set, or the product did not exist.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditChanges are the changed fields of an audit entry keyed by field name.
+type AuditChanges map[string]FieldChange
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c *AuditChanges) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*c = AuditChanges{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into AuditChanges", value)
+	}
+	return json.Unmarshal(b, c)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+)
+
+type Category struct {
+	ID         string          `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID   string          `json:"tenant_id" gorm:"not null;default:'default';uniqueIndex:idx_categories_tenant_slug,priority:1"`
+	Name       string          `json:"name" gorm:"not null"`
+	Slug       string          `json:"slug" gorm:"not null;uniqueIndex:idx_categories_tenant_slug,priority:2"`
+	ParentID   *string         `json:"parent_id" gorm:"type:uuid;index"`
+	Parent     *Category       `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	Attributes AttributeSchema `json:"attributes" gorm:"type:jsonb;not null;default:'[]'"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// AttributeDef describes one product attribute of a category. Values limits
+// string attributes to a fixed set.
+type AttributeDef struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// AttributeSchema is the list of attributes products of a category may have.
+// Subcategories inherit the schema of their ancestors.
+type AttributeSchema []AttributeDef
+
+func (s AttributeSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *AttributeSchema) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*s = AttributeSchema{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into AttributeSchema", value)
+	}
+	return json.Unmarshal(b, s)
+}
+
+func (AttributeSchema) GormDataType() string {
+	return "jsonb"
+}
+
+// Slugify turns a category name into its slug, e.g. "Home & Garden" into
+// "home-garden".
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
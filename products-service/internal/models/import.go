@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+const (
+	ImportActionCreated   = "created"
+	ImportActionUpdated   = "updated"
+	ImportActionUnchanged = "unchanged"
+)
+
+// ProductImport tracks an asynchronous catalog import. Errors and Changes
+// are capped, the counters always cover every row.
+type ProductImport struct {
+	ID         string     `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID   string     `json:"tenant_id" gorm:"not null;default:'default';index"`
+	Format     string     `json:"format" gorm:"not null"`
+	DryRun     bool       `json:"dry_run" gorm:"not null;default:false"`
+	Status     string     `json:"status" gorm:"not null;default:'pending';index"`
+	Processed  int        `json:"processed" gorm:"not null;default:0"`
+	Created    int        `json:"created" gorm:"not null;default:0"`
+	Updated    int        `json:"updated" gorm:"not null;default:0"`
+	Unchanged  int        `json:"unchanged" gorm:"not null;default:0"`
+	Failed     int        `json:"failed" gorm:"not null;default:0"`
+	Errors     ImportRows `json:"errors" gorm:"type:jsonb;not null;default:'[]'"`
+	Changes    ImportRows `json:"changes,omitempty" gorm:"type:jsonb;not null;default:'[]'"`
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  string     `json:"created_by" gorm:"not null"`
+	RequestID  string     `json:"request_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// ImportRow reports the outcome of one imported row. Row is the line number
+// in the uploaded file.
+type ImportRow struct {
+	Row         int      `json:"row"`
+	ExternalSKU string   `json:"external_sku,omitempty"`
+	Action      string   `json:"action,omitempty"`
+	Fields      []string `json:"fields,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type ImportRows []ImportRow
+
+func (rows ImportRows) Value() (driver.Value, error) {
+	if rows == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (rows *ImportRows) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*rows = ImportRows{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ImportRows", value)
+	}
+	return json.Unmarshal(b, rows)
+}
+
+func (ImportRows) GormDataType() string {
+	return "jsonb"
+}
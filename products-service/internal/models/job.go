@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+const (
+	JobTypeBulkUpdate = "bulk_update"
+)
+
+// Job is a persisted background operation. Payload is the submitted input,
+// Result the output so far, which lets an interrupted job resume.
+type Job struct {
+	ID              string     `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID        string     `json:"tenant_id" gorm:"not null;default:'default';index"`
+	Type            string     `json:"type" gorm:"not null;index"`
+	Status          string     `json:"status" gorm:"not null;default:'queued';index:idx_jobs_status,priority:1"`
+	Payload         JSONRaw    `json:"-" gorm:"type:jsonb;not null"`
+	Result          JSONRaw    `json:"result,omitempty" gorm:"type:jsonb"`
+	Total           int        `json:"total" gorm:"not null;default:0"`
+	Processed       int        `json:"processed" gorm:"not null;default:0"`
+	Succeeded       int        `json:"succeeded" gorm:"not null;default:0"`
+	Failed          int        `json:"failed" gorm:"not null;default:0"`
+	Error           string     `json:"error,omitempty"`
+	CancelRequested bool       `json:"cancel_requested" gorm:"not null;default:false"`
+	CreatedBy       string     `json:"created_by" gorm:"not null"`
+	RequestID       string     `json:"request_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime;index:idx_jobs_status,priority:2"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+}
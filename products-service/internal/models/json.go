@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONMap is a JSON object stored in a jsonb column.
+type JSONMap map[string]any
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (m *JSONMap) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*m = JSONMap{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", value)
+	}
+	return json.Unmarshal(b, m)
+}
+
+func (JSONMap) GormDataType() string {
+	return "jsonb"
+}
+
+// JSONRaw is an arbitrary JSON document stored in a jsonb column.
+type JSONRaw json.RawMessage
+
+func (j JSONRaw) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+func (j *JSONRaw) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSONRaw(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONRaw", value)
+	}
+	return nil
+}
+
+func (j JSONRaw) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSONRaw) UnmarshalJSON(b []byte) error {
+	*j = append((*j)[:0], b...)
+	return nil
+}
+
+func (JSONRaw) GormDataType() string {
+	return "jsonb"
+}
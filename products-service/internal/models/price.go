@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ScheduleStatusPending   = "pending"
+	ScheduleStatusApplied   = "applied"
+	ScheduleStatusCancelled = "cancelled"
+	ScheduleStatusFailed    = "failed"
+)
+
+// PriceChange is one entry of a product's price history. A nil OldPrice means
+// the price was first set, a nil NewPrice means it was removed.
+type PriceChange struct {
+	ID          string    `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ProductID   string    `json:"product_id" gorm:"type:uuid;not null;index:idx_price_history_product,priority:1"`
+	Product     *Product  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Currency    string    `json:"currency" gorm:"type:char(3);not null"`
+	OldPrice    *float64  `json:"old_price"`
+	NewPrice    *float64  `json:"new_price"`
+	Source      string    `json:"source" gorm:"not null"`
+	ChangedBy   string    `json:"changed_by" gorm:"not null"`
+	EffectiveAt time.Time `json:"effective_at" gorm:"not null;index:idx_price_history_product,priority:2"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (PriceChange) TableName() string {
+	return "price_history"
+}
+
+// ScheduledPrice is a future price change applied by the price scheduler.
+type ScheduledPrice struct {
+	ID          string     `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ProductID   string     `json:"product_id" gorm:"type:uuid;not null;index"`
+	Product     *Product   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Currency    string     `json:"currency" gorm:"type:char(3);not null"`
+	Price       float64    `json:"price" gorm:"not null"`
+	EffectiveAt time.Time  `json:"effective_at" gorm:"not null;index:idx_scheduled_prices_due,priority:2"`
+	Status      string     `json:"status" gorm:"not null;default:'pending';index:idx_scheduled_prices_due,priority:1"`
+	CreatedBy   string     `json:"created_by" gorm:"not null"`
+	AppliedAt   *time.Time `json:"applied_at"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	LastError   string     `json:"-" gorm:"not null;default:''"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (ScheduledPrice) TableName() string {
+	return "scheduled_price_changes"
+}
@@ -5,13 +5,58 @@ import (
 	// "gorm.io/gorm"
 )
 
+const (
+	ProductStatusDraft        = "draft"
+	ProductStatusActive       = "active"
+	ProductStatusDiscontinued = "discontinued"
+	ProductStatusArchived     = "archived"
+)
+
+// productTransitions maps each status to the one it may move to.
+var productTransitions = map[string]string{
+	ProductStatusDraft:        ProductStatusActive,
+	ProductStatusActive:       ProductStatusDiscontinued,
+	ProductStatusDiscontinued: ProductStatusArchived,
+}
+
+// ValidProductStatus reports whether status is a known product status.
+func ValidProductStatus(status string) bool {
+	switch status {
+	case ProductStatusDraft, ProductStatusActive, ProductStatusDiscontinued, ProductStatusArchived:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a product may move from one status to
+// another: draft, active, discontinued, archived, in that order.
+func CanTransition(from, to string) bool {
+	return productTransitions[from] == to
+}
+
 type Product struct {
-	ID          string    `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Name        string    `json:"name" gorm:"not null;index"`
-	Description string    `json:"description" gorm:"not null"`
-	Price       float64   `json:"price" gorm:"not null;index"`
-	Category    string    `json:"category" gorm:"not null;index"`
-	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID          string         `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID    string         `json:"tenant_id" gorm:"not null;default:'default';index;uniqueIndex:idx_products_tenant_external_sku,priority:1"`
+	ExternalSKU *string        `json:"external_sku,omitempty" gorm:"uniqueIndex:idx_products_tenant_external_sku,priority:2"`
+	Name        string         `json:"name" gorm:"not null;index"`
+	Description string         `json:"description" gorm:"not null"`
+	Price       float64        `json:"price" gorm:"not null;index"`
+	Currency    string         `json:"currency" gorm:"type:char(3);not null;default:'SAR';index"`
+	Prices      []ProductPrice `json:"prices" gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"`
+	Category    string         `json:"category" gorm:"not null;index"`
+	Status      string         `json:"status" gorm:"not null;default:'active';index"`
+	Attributes  JSONMap        `json:"attributes" gorm:"type:jsonb;not null;default:'{}';index:idx_products_attributes,type:gin"`
+	Version     int            `json:"version" gorm:"not null;default:1"`
+	Variants    []Variant      `json:"variants,omitempty"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	// DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
+
+// ProductPrice is an explicit price of a product in a currency other than
+// the product's default currency.
+type ProductPrice struct {
+	ProductID string  `json:"-" gorm:"type:uuid;primaryKey"`
+	Currency  string  `json:"currency" gorm:"type:char(3);primaryKey;index"`
+	Amount    float64 `json:"amount" gorm:"not null"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{ProductStatusDraft, ProductStatusActive, true},
+		{ProductStatusActive, ProductStatusDiscontinued, true},
+		{ProductStatusDiscontinued, ProductStatusArchived, true},
+
+		{ProductStatusDraft, ProductStatusDraft, false},
+		{ProductStatusDraft, ProductStatusDiscontinued, false},
+		{ProductStatusDraft, ProductStatusArchived, false},
+		{ProductStatusActive, ProductStatusDraft, false},
+		{ProductStatusActive, ProductStatusArchived, false},
+		{ProductStatusDiscontinued, ProductStatusActive, false},
+		{ProductStatusArchived, ProductStatusDraft, false},
+		{ProductStatusArchived, ProductStatusActive, false},
+		{"", ProductStatusActive, false},
+		{ProductStatusDraft, "", false},
+		{"unknown", ProductStatusActive, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidProductStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{ProductStatusDraft, true},
+		{ProductStatusActive, true},
+		{ProductStatusDiscontinued, true},
+		{ProductStatusArchived, true},
+		{"", false},
+		{"Active", false},
+		{"deleted", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidProductStatus(tt.status); got != tt.want {
+			t.Errorf("ValidProductStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Variant is a sellable configuration (SKU) of a product, e.g. the 32GB
+// "Lenovo Laptop". PriceOverride is in the product's default currency.
+type Variant struct {
+	ID            string    `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID      string    `json:"tenant_id" gorm:"not null;default:'default';uniqueIndex:idx_variants_tenant_sku,priority:1;uniqueIndex:idx_variants_tenant_barcode,priority:1"`
+	ProductID     string    `json:"product_id" gorm:"type:uuid;not null;index"`
+	Product       *Product  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	SKU           string    `json:"sku" gorm:"not null;uniqueIndex:idx_variants_tenant_sku,priority:2"`
+	Options       JSONMap   `json:"options" gorm:"type:jsonb;not null;default:'{}'"`
+	PriceOverride *float64  `json:"price_override"`
+	Barcode       *string   `json:"barcode" gorm:"uniqueIndex:idx_variants_tenant_barcode,priority:2"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,117 @@
+package pricing
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	SourceCreate   = "create"
+	SourceUpdate   = "update"
+	SourceBulk     = "bulk_update"
+	SourceSchedule = "schedule"
+	SourceImport   = "import"
+)
+
+// Snapshot returns every explicit price of a product keyed by currency.
+func Snapshot(p models.Product) map[string]float64 {
+	prices := make(map[string]float64, len(p.Prices)+1)
+	for _, pp := range p.Prices {
+		prices[pp.Currency] = pp.Amount
+	}
+	prices[p.Currency] = p.Price
+	return prices
+}
+
+// Record stores one history entry per currency whose price differs between
+// the two snapshots.
+func Record(tx *gorm.DB, productID string, before, after map[string]float64, source, actor string, effectiveAt time.Time) error {
+	currencies := make([]string, 0, len(before)+len(after))
+	for code := range before {
+		currencies = append(currencies, code)
+	}
+	for code := range after {
+		if _, ok := before[code]; !ok {
+			currencies = append(currencies, code)
+		}
+	}
+	sort.Strings(currencies)
+
+	var changes []models.PriceChange
+	for _, code := range currencies {
+		oldPrice, hadOld := before[code]
+		newPrice, hasNew := after[code]
+		if hadOld && hasNew && oldPrice == newPrice {
+			continue
+		}
+
+		change := models.PriceChange{
+			ProductID:   productID,
+			Currency:    code,
+			Source:      source,
+			ChangedBy:   actor,
+			EffectiveAt: effectiveAt,
+		}
+		if hadOld {
+			change.OldPrice = &oldPrice
+		}
+		if hasNew {
+			change.NewPrice = &newPrice
+		}
+		changes = append(changes, change)
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
+
+// Apply sets the price of product in cur, either its default price or a
+// price list entry, and records the change.
+func Apply(tx *gorm.DB, product *models.Product, cur string, price float64, source, actor string, effectiveAt time.Time) error {
+	before := Snapshot(*product)
+
+	if cur == product.Currency {
+		err := tx.Model(&models.Product{}).Where("id = ?", product.ID).Updates(map[string]any{
+			"price":   price,
+			"version": gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		product.Price = price
+	} else {
+		entry := models.ProductPrice{ProductID: product.ID, Currency: cur, Amount: price}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+			DoUpdates: clause.AssignmentColumns([]string{"amount"}),
+		}).Create(&entry).Error
+		if err != nil {
+			return err
+		}
+
+		replaced := false
+		for i := range product.Prices {
+			if product.Prices[i].Currency == cur {
+				product.Prices[i].Amount = price
+				replaced = true
+			}
+		}
+		if !replaced {
+			product.Prices = append(product.Prices, entry)
+		}
+
+		err = tx.Model(&models.Product{}).Where("id = ?", product.ID).Update("version", gorm.Expr("version + 1")).Error
+		if err != nil {
+			return err
+		}
+	}
+	product.Version++
+
+	return Record(tx, product.ID, before, Snapshot(*product), source, actor, effectiveAt)
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/audit"
+	"github.com/MosaabBleik/products-service/internal/cache"
+	"github.com/MosaabBleik/products-service/internal/models"
+	"github.com/MosaabBleik/products-service/internal/pricing"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	batchSize = 100
+	// maxAttempts is how often a change is tried before it is marked failed
+	maxAttempts = 3
+)
+
+// PriceScheduler applies scheduled price changes once they become due.
+type PriceScheduler struct {
+	DB          *gorm.DB
+	RedisClient *redis.Client
+	Interval    time.Duration
+}
+
+// Run polls for due changes until ctx is cancelled.
+func (s *PriceScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		applied, err := s.ApplyDue(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "price scheduler failed", "error", err)
+		}
+		if applied > 0 {
+			slog.InfoContext(ctx, "price scheduler: applied scheduled price changes", "count", applied)
+			if err := cache.InvalidateAllSearches(ctx, s.RedisClient); err != nil {
+				slog.ErrorContext(ctx, "price scheduler: failed to invalidate search cache", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyDue applies every pending change whose effective time has passed.
+// Rows are locked with SKIP LOCKED so several replicas can run the scheduler.
+// A change that fails is retried on the next ticks, then marked failed.
+func (s *PriceScheduler) ApplyDue(ctx context.Context) (int, error) {
+	total := 0
+
+	for {
+		applied, fetched, failed, err := s.applyBatch(ctx)
+		total += applied
+		if err != nil || fetched < batchSize || failed > 0 {
+			return total, err
+		}
+	}
+}
+
+func (s *PriceScheduler) applyBatch(ctx context.Context) (applied, fetched, failed int, err error) {
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []models.ScheduledPrice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND effective_at <= ?", models.ScheduleStatusPending, time.Now()).
+			Order("effective_at ASC").
+			Limit(batchSize).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+		fetched = len(due)
+
+		for _, sc := range due {
+			// Each change runs in a savepoint, so one that fails neither
+			// undoes nor blocks the others
+			var ok bool
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				var err error
+				ok, err = applyChange(sp, sc)
+				return err
+			})
+			if err == nil {
+				if ok {
+					applied++
+				}
+				continue
+			}
+
+			failed++
+			attempts := sc.Attempts + 1
+			slog.WarnContext(ctx, "price scheduler: failed to apply scheduled price change",
+				"schedule_id", sc.ID, "product_id", sc.ProductID, "attempt", attempts, "error", err)
+
+			updates := map[string]any{"attempts": attempts, "last_error": err.Error()}
+			if attempts >= maxAttempts {
+				updates["status"] = models.ScheduleStatusFailed
+			}
+			if err := tx.Model(&sc).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return applied, fetched, failed, nil
+}
+
+// applyChange applies one scheduled change and reports whether it did. The
+// change of a deleted product is cancelled instead.
+func applyChange(tx *gorm.DB, sc models.ScheduledPrice) (bool, error) {
+	var product models.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Prices").
+		Where("id = ?", sc.ProductID).
+		First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, tx.Model(&sc).Update("status", models.ScheduleStatusCancelled).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	before := audit.Snapshot(product)
+	if err := pricing.Apply(tx, &product, sc.Currency, sc.Price, pricing.SourceSchedule, sc.CreatedBy, sc.EffectiveAt); err != nil {
+		return false, err
+	}
+	change := audit.Change{Tenant: product.TenantID, Action: audit.ActionSchedule, Actor: sc.CreatedBy}
+	if err := audit.Record(tx, product.ID, change, before, audit.Snapshot(product)); err != nil {
+		return false, err
+	}
+
+	err = tx.Model(&sc).Updates(map[string]any{
+		"status":     models.ScheduleStatusApplied,
+		"applied_at": time.Now(),
+	}).Error
+	return err == nil, err
+}
@@ -0,0 +1,81 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/MosaabBleik/products-service/internal/auth"
+	"github.com/MosaabBleik/products-service/internal/problem"
+)
+
+const (
+	// Header selects the tenant of a request
+	Header = "X-Tenant-ID"
+	// Default is the tenant of requests that name none, and of the data that
+	// existed before tenants were introduced
+	Default = "default"
+)
+
+type tenantKey struct{}
+
+// Valid reports whether id is a well-formed tenant ID.
+func Valid(id string) bool {
+	return auth.ValidTenant(id)
+}
+
+// WithTenant returns a copy of ctx that belongs to tenant id.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant ctx belongs to, the default tenant if none
+// was resolved.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(tenantKey{}).(string); ok {
+		return id
+	}
+	return Default
+}
+
+// Resolve sets the tenant of every request from the tenant of the principal,
+// or else the X-Tenant-ID header, or else the default tenant. A principal
+// bound to a tenant cannot name another one in the header, and only service
+// principals without a tenant, such as a shared API key, may pick one: users
+// whose token carries no tenant belong to the default tenant. A principal
+// bound to a malformed tenant is rejected. Without
+// authentication, when it is disabled, the header is trusted. It must run
+// after authentication.
+func Resolve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.ToLower(strings.TrimSpace(r.Header.Get(Header)))
+		if id != "" && !Valid(id) {
+			problem.Write(w, http.StatusBadRequest, "invalid_tenant", "Invalid "+Header+": use lowercase letters, digits, '-' and '_'")
+			return
+		}
+
+		principal, ok := auth.FromContext(r.Context())
+		bound := strings.ToLower(strings.TrimSpace(principal.Tenant))
+		if ok && (bound != "" || !principal.Service) {
+			if bound == "" {
+				bound = Default
+			}
+			// A token's tenant ends up in cache keys and queries like a header's
+			if !Valid(bound) {
+				problem.Write(w, http.StatusForbidden, "invalid_tenant", "Credentials name an invalid tenant")
+				return
+			}
+			if id != "" && id != bound {
+				problem.Write(w, http.StatusForbidden, "tenant_forbidden", "Credentials are not valid for tenant "+id)
+				return
+			}
+			id = bound
+		}
+		if id == "" {
+			id = Default
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), id)))
+	})
+}
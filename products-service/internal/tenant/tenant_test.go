@@ -0,0 +1,138 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MosaabBleik/products-service/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func userToken(t *testing.T, tenant string) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub":   "user-1",
+		"roles": []string{auth.RoleReader},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	if tenant != "" {
+		claims["tenant_id"] = tenant
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"default", true},
+		{"acme", true},
+		{"acme-eu_2", true},
+		{"0", true},
+		{"", false},
+		{"-acme", false},
+		{"_acme", false},
+		{"Acme", false},
+		{"acme corp", false},
+		{"acme*", false},
+		{"acme:1", false},
+		{"a123456789012345678901234567890123456789012345678901234567890123", false},
+	}
+
+	for _, tt := range tests {
+		if got := Valid(tt.id); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	a := &auth.Authenticator{Secret: []byte(testSecret), RolesClaim: "roles", TenantClaim: "tenant_id"}
+	a.AddAPIKey("shared", "shared-key", []string{auth.RoleReader}, "")
+	a.AddAPIKey("acme", "acme-key", []string{auth.RoleReader}, "acme")
+
+	tests := []struct {
+		name       string
+		header     string
+		apiKey     string
+		token      string
+		wantStatus int
+		wantTenant string
+	}{
+		{name: "anonymous without header", wantStatus: http.StatusOK, wantTenant: Default},
+		{name: "anonymous picks by header", header: "acme", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "header is normalized", header: "  ACME ", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "invalid header", header: "acme corp", wantStatus: http.StatusBadRequest},
+
+		{name: "shared key without header", apiKey: "shared-key", wantStatus: http.StatusOK, wantTenant: Default},
+		{name: "shared key picks by header", apiKey: "shared-key", header: "globex", wantStatus: http.StatusOK, wantTenant: "globex"},
+
+		{name: "tenant key", apiKey: "acme-key", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "tenant key with own header", apiKey: "acme-key", header: "acme", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "tenant key with other header", apiKey: "acme-key", header: "globex", wantStatus: http.StatusForbidden},
+
+		{name: "user with tenant", token: userToken(t, "acme"), wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "user with other header", token: userToken(t, "acme"), header: "globex", wantStatus: http.StatusForbidden},
+		{name: "user without tenant", token: userToken(t, ""), wantStatus: http.StatusOK, wantTenant: Default},
+		{name: "user without tenant with default header", token: userToken(t, ""), header: Default, wantStatus: http.StatusOK, wantTenant: Default},
+		{name: "user without tenant cannot pick", token: userToken(t, ""), header: "acme", wantStatus: http.StatusForbidden},
+		{name: "user with mixed case tenant", token: userToken(t, " Acme "), header: "acme", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "user with wildcard tenant", token: userToken(t, "*"), wantStatus: http.StatusForbidden},
+		{name: "user with pattern tenant", token: userToken(t, "a*"), header: "acme", wantStatus: http.StatusForbidden},
+		{name: "user with blank tenant", token: userToken(t, "  "), wantStatus: http.StatusOK, wantTenant: Default},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := a.Authenticate(Resolve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = FromContext(r.Context())
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", got, tt.wantTenant)
+			}
+			if h := rec.Header().Get(Header); h != tt.wantTenant {
+				t.Errorf("%s response header = %q, want %q", Header, h, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := FromContext(req.Context()); got != Default {
+		t.Errorf("FromContext() = %q, want %q", got, Default)
+	}
+	if got := FromContext(WithTenant(req.Context(), "acme")); got != "acme" {
+		t.Errorf("FromContext() = %q, want %q", got, "acme")
+	}
+}
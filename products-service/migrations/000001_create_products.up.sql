@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS products (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL,
+    price decimal NOT NULL,
+    category text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_name ON products (name);
+CREATE INDEX IF NOT EXISTS idx_products_price ON products (price);
+CREATE INDEX IF NOT EXISTS idx_products_category ON products (category);
@@ -0,0 +1,12 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency char(3) NOT NULL DEFAULT 'SAR';
+CREATE INDEX IF NOT EXISTS idx_products_currency ON products (currency);
+
+CREATE TABLE IF NOT EXISTS product_prices (
+    product_id uuid NOT NULL,
+    currency char(3) NOT NULL,
+    amount decimal NOT NULL,
+    PRIMARY KEY (product_id, currency),
+    CONSTRAINT fk_products_prices FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_currency ON product_prices (currency);
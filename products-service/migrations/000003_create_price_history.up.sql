@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS price_history (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    product_id uuid NOT NULL,
+    currency char(3) NOT NULL,
+    old_price decimal,
+    new_price decimal,
+    source text NOT NULL,
+    changed_by text NOT NULL,
+    effective_at timestamptz NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT fk_price_history_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_history_product ON price_history (product_id, effective_at);
+
+CREATE TABLE IF NOT EXISTS scheduled_price_changes (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    product_id uuid NOT NULL,
+    currency char(3) NOT NULL,
+    price decimal NOT NULL,
+    effective_at timestamptz NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    created_by text NOT NULL,
+    applied_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_scheduled_price_changes_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_price_changes_product_id ON scheduled_price_changes (product_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_due ON scheduled_price_changes (status, effective_at);
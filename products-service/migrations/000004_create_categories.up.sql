@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    name text NOT NULL,
+    slug text NOT NULL,
+    parent_id uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories (id) ON DELETE RESTRICT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
@@ -0,0 +1 @@
+-- The original free-text categories are not kept, backfilled categories stay.
@@ -0,0 +1,16 @@
+-- Turn legacy free-text categories into managed ones: create a category per
+-- distinct value and rewrite products to its slug, e.g. "Home & Garden" into
+-- "home-garden".
+INSERT INTO categories (name, slug, created_at, updated_at)
+SELECT DISTINCT ON (slug) category, slug, now(), now()
+FROM (
+    SELECT category, trim(BOTH '-' FROM regexp_replace(lower(trim(category)), '[^[:alnum:]]+', '-', 'g')) AS slug
+    FROM products
+) p
+WHERE slug <> ''
+ORDER BY slug, category
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE products
+SET category = trim(BOTH '-' FROM regexp_replace(lower(trim(category)), '[^[:alnum:]]+', '-', 'g'))
+WHERE trim(BOTH '-' FROM regexp_replace(lower(trim(category)), '[^[:alnum:]]+', '-', 'g')) NOT IN ('', category);
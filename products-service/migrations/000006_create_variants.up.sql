@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS variants (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    product_id uuid NOT NULL,
+    sku text NOT NULL,
+    options jsonb NOT NULL DEFAULT '{}',
+    price_override decimal,
+    barcode text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_variants_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_variants_product_id ON variants (product_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_sku ON variants (sku);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_barcode ON variants (barcode);
@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes jsonb NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING gin (attributes);
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS attributes jsonb NOT NULL DEFAULT '[]';
@@ -0,0 +1,23 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS external_sku text;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_external_sku ON products (external_sku);
+
+CREATE TABLE IF NOT EXISTS product_imports (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    format text NOT NULL,
+    dry_run boolean NOT NULL DEFAULT false,
+    status text NOT NULL DEFAULT 'pending',
+    processed bigint NOT NULL DEFAULT 0,
+    created bigint NOT NULL DEFAULT 0,
+    updated bigint NOT NULL DEFAULT 0,
+    unchanged bigint NOT NULL DEFAULT 0,
+    failed bigint NOT NULL DEFAULT 0,
+    errors jsonb NOT NULL DEFAULT '[]',
+    changes jsonb NOT NULL DEFAULT '[]',
+    error text,
+    created_by text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    finished_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_imports_status ON product_imports (status);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    type text NOT NULL,
+    status text NOT NULL DEFAULT 'queued',
+    payload jsonb NOT NULL,
+    result jsonb,
+    total bigint NOT NULL DEFAULT 0,
+    processed bigint NOT NULL DEFAULT 0,
+    succeeded bigint NOT NULL DEFAULT 0,
+    failed bigint NOT NULL DEFAULT 0,
+    error text,
+    cancel_requested boolean NOT NULL DEFAULT false,
+    created_by text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    started_at timestamptz,
+    finished_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs (type);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status, created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE jobs DROP COLUMN IF EXISTS request_id;
+ALTER TABLE product_imports DROP COLUMN IF EXISTS request_id;
+
+DROP TABLE IF EXISTS product_audit;
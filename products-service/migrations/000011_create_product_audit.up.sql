@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS product_audit (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    product_id uuid NOT NULL,
+    action text NOT NULL,
+    actor text NOT NULL,
+    request_id text,
+    changes jsonb NOT NULL DEFAULT '{}',
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_audit_product ON product_audit (product_id, created_at);
+
+ALTER TABLE product_imports ADD COLUMN IF NOT EXISTS request_id text;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS request_id text;
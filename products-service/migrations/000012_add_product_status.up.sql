@@ -0,0 +1,4 @@
+-- Products created before publication statuses existed stay visible
+ALTER TABLE products ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'active';
+
+CREATE INDEX IF NOT EXISTS idx_products_status ON products (status);
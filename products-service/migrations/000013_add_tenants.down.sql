@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_jobs_tenant_id;
+ALTER TABLE jobs DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_product_imports_tenant_id;
+ALTER TABLE product_imports DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE product_audit DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_products_tenant_external_sku;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_external_sku ON products (external_sku);
+
+DROP INDEX IF EXISTS idx_products_tenant_id;
+ALTER TABLE products DROP COLUMN IF EXISTS tenant_id;
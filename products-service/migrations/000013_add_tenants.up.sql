@@ -0,0 +1,15 @@
+-- Existing data belongs to the default tenant
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_products_tenant_id ON products (tenant_id);
+
+-- External SKUs are unique within a tenant
+DROP INDEX IF EXISTS idx_products_external_sku;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_tenant_external_sku ON products (tenant_id, external_sku);
+
+ALTER TABLE product_audit ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+ALTER TABLE product_imports ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_product_imports_tenant_id ON product_imports (tenant_id);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_id ON jobs (tenant_id);
@@ -0,0 +1,8 @@
+-- Only the categories of the default tenant are kept
+UPDATE categories SET parent_id = NULL WHERE tenant_id <> 'default';
+DELETE FROM categories WHERE tenant_id <> 'default';
+
+DROP INDEX IF EXISTS idx_categories_tenant_slug;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+
+ALTER TABLE categories DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,24 @@
+-- Categories belong to a tenant, their slugs are unique within it
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS idx_categories_slug;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_tenant_slug ON categories (tenant_id, slug);
+
+-- Existing categories stay with the default tenant and are copied to every
+-- other tenant with products, so those keep their categories and schemas
+INSERT INTO categories (tenant_id, name, slug, attributes, created_at, updated_at)
+SELECT t.tenant_id, c.name, c.slug, c.attributes, c.created_at, c.updated_at
+FROM categories c
+CROSS JOIN (SELECT DISTINCT tenant_id FROM products WHERE tenant_id <> 'default') t
+WHERE c.tenant_id = 'default'
+ON CONFLICT (tenant_id, slug) DO NOTHING;
+
+-- Copies get the copy of their parent
+UPDATE categories c
+SET parent_id = copy_parent.id
+FROM categories original, categories original_parent, categories copy_parent
+WHERE c.tenant_id <> 'default'
+  AND c.parent_id IS NULL
+  AND original.tenant_id = 'default' AND original.slug = c.slug
+  AND original_parent.id = original.parent_id
+  AND copy_parent.tenant_id = c.tenant_id AND copy_parent.slug = original_parent.slug;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_variants_tenant_barcode;
+DROP INDEX IF EXISTS idx_variants_tenant_sku;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_barcode ON variants (barcode);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_sku ON variants (sku);
+
+ALTER TABLE variants DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,13 @@
+-- Variants belong to the tenant of their product, SKUs and barcodes are
+-- unique within it
+ALTER TABLE variants ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT 'default';
+
+UPDATE variants v
+SET tenant_id = p.tenant_id
+FROM products p
+WHERE p.id = v.product_id AND v.tenant_id <> p.tenant_id;
+
+DROP INDEX IF EXISTS idx_variants_sku;
+DROP INDEX IF EXISTS idx_variants_barcode;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_tenant_sku ON variants (tenant_id, sku);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_tenant_barcode ON variants (tenant_id, barcode);
@@ -0,0 +1,4 @@
+UPDATE scheduled_price_changes SET status = 'pending' WHERE status = 'failed';
+
+ALTER TABLE scheduled_price_changes DROP COLUMN IF EXISTS last_error;
+ALTER TABLE scheduled_price_changes DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,3 @@
+-- Changes that keep failing are marked failed after a few attempts
+ALTER TABLE scheduled_price_changes ADD COLUMN IF NOT EXISTS attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE scheduled_price_changes ADD COLUMN IF NOT EXISTS last_error text NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+// Package migrations holds the versioned SQL schema of the products service.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS